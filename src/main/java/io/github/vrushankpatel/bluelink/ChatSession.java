@@ -1,134 +1,2347 @@
 package io.github.vrushankpatel.bluelink;
 
+import com.google.gson.Gson;
+import com.google.gson.GsonBuilder;
 import io.github.vrushankpatel.bluelink.config.UserConfig;
 import io.github.vrushankpatel.bluelink.firebase.FirebaseClient;
 import io.github.vrushankpatel.bluelink.firebase.Message;
+import io.github.vrushankpatel.bluelink.firebase.NetStats;
+import io.github.vrushankpatel.bluelink.firebase.Participant;
+import io.github.vrushankpatel.bluelink.firebase.ReadOnlyException;
+import io.github.vrushankpatel.bluelink.firebase.Report;
+import io.github.vrushankpatel.bluelink.firebase.RoomMeta;
 
+import java.io.Writer;
+import java.lang.management.ManagementFactory;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.time.Instant;
+import java.time.ZoneId;
+import java.time.format.DateTimeFormatter;
+import java.util.ArrayDeque;
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.Comparator;
+import java.util.Deque;
+import java.util.HashSet;
+import java.util.LinkedHashMap;
 import java.util.List;
+import java.util.Map;
+import java.util.Queue;
 import java.util.Scanner;
+import java.util.Set;
+import java.util.UUID;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.CopyOnWriteArrayList;
+import java.util.concurrent.ConcurrentLinkedQueue;
+import java.util.concurrent.ExecutorService;
+import java.util.concurrent.RejectedExecutionException;
 import java.util.concurrent.Executors;
 import java.util.concurrent.ScheduledExecutorService;
+import java.util.concurrent.ScheduledThreadPoolExecutor;
+import java.util.concurrent.ThreadFactory;
 import java.util.concurrent.TimeUnit;
 import java.util.concurrent.atomic.AtomicBoolean;
 import java.util.concurrent.atomic.AtomicLong;
+import java.util.concurrent.atomic.AtomicReference;
+import java.util.concurrent.locks.ReentrantLock;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
 
 /**
  * Manages the active chat session: polling for new messages and reading user input.
  */
 public class ChatSession {
 
+    // Longer participant lists are cut off with a "+N more" footer unless /who all is used
+    private static final int WHO_LIMIT = 20;
+
+    // Entries shown by /activity
+    private static final int ACTIVITY_LIMIT = 20;
+
+    // Messages shown either side of the one /goto jumps to
+    private static final int GOTO_CONTEXT = 3;
+
+    // Commands that only read, so they stay available with --readonly
+    private static final Set<String> SPECTATOR_COMMANDS = Set.of(
+            "/help", "/exit", "/clear", "/inspect", "/when", "/goto", "/export", "/expand", "/preview",
+            "/notify", "/mutenotify", "/timestamps", "/ts", "/poll", "/netstats", "/more", "/pause", "/resume", "/who",
+            "/activity", "/rooms", "/join", "/find", "/security", "/key", "/debug-dump", "/debug-mem");
+
+    // How often polling re-reads the latest page to pick up edits, reactions and deletions
+    private static final long REFRESH_MS = 10_000;
+
+    // The same message typed again within this long is taken for a double send and dropped
+    private static final long DOUBLE_SEND_MS = 2_000;
+
+    private static final int MAX_NOTICE = 200;
+    private static final int MAX_WELCOME = 1000;
+
+    // Bounds for /poll; lower means snappier delivery but more database reads
+    private static final long MIN_POLL_MS = 100;
+    private static final long MAX_POLL_MS = 60_000;
+
+    // Automatic resends after a failed send while connected: 2s, 4s, 8s, 16s, 32s, then /retry only
+    private static final int MAX_AUTO_RETRIES = 5;
+
+    private static final String HIGHLIGHT = "\033[1;7m";
+    private static final String ITALIC    = "\033[3m";
+    private static final String ACCENT    = "\033[30;46m";
+    private static final String BOLD      = "\033[1m";
+    private static final String DIM       = "\033[2m";
+    private static final String RESET     = "\033[0m";
+
+    private static final Gson DEBUG_GSON = new GsonBuilder().setPrettyPrinting().create();
+
     private final String roomId;
     private final UserConfig config;
     private final FirebaseClient firebase;
     private final Scanner scanner;
+    private final CliOptions options;
+    private final Pattern alertPattern;   // null when no alert keywords are configured
+    private final ColorProfile colors;
+    private final String selfStyle;       // ANSI color for your own name, null when selfColor is unset
 
     private final AtomicBoolean running = new AtomicBoolean(true);
-    private final AtomicLong lastTimestamp = new AtomicLong(0);
-    private final ScheduledExecutorService scheduler = Executors.newScheduledThreadPool(2);
+    private volatile boolean joined;
+    private volatile String nextRoom;   // set by /join; run() returns it once this session has left
+    private volatile String partingMessage;
+    // Push keys of the newest message and event received; polls and listeners resume from them
+    private final AtomicReference<String> lastKey      = new AtomicReference<>();
+    private final AtomicReference<String> lastEventKey = new AtomicReference<>();
+    private final AtomicLong pollMillis = new AtomicLong(500);
+    private volatile long lastRefresh;   // when polling last re-read the latest page for edits
+
+    // Messages pushed by the live listener, waiting for the next batch; unsubscribe is null when polling
+    private final Queue<Message> incoming = new ConcurrentLinkedQueue<>();
+    private final Queue<String>  removals = new ConcurrentLinkedQueue<>();   // keys of deleted messages, applied with the next batch
+    private volatile Runnable unsubscribe;
+
+    // Joins and departures from the room's events node, oldest first
+    private final List<Message> roomEvents = Collections.synchronizedList(new ArrayList<>());
+    private final Set<String> eventKeys = ConcurrentHashMap.newKeySet();
+    private volatile Runnable unwatchEvents;
+
+    // Threads of the executors below, so stop() run on one of them doesn't wait for itself
+    private final Set<Thread> ownThreads = ConcurrentHashMap.newKeySet();
+    private final ThreadFactory threads = r -> {
+        Thread t = Executors.defaultThreadFactory().newThread(r);
+        ownThreads.add(t);
+        return t;
+    };
+    private final ScheduledExecutorService scheduler = Executors.newScheduledThreadPool(2, threads);
+    private final ScheduledExecutorService heartbeat = Executors.newSingleThreadScheduledExecutor(threads);
+    private final ScheduledExecutorService resender  = Executors.newSingleThreadScheduledExecutor(threads);   // outbox resends, off the poll threads
+    private final ExecutorService previews = Executors.newSingleThreadExecutor(threads);
+    private final ExecutorService notifier = Executors.newSingleThreadExecutor(threads);
+
+    // Every message shown this session, oldest first — commands address them by index
+    private final List<Message> messages = Collections.synchronizedList(new ArrayList<>());
+    private volatile RoomMeta meta;
+    private volatile String lastError;
+
+    // Messages whose send failed or that were typed offline, oldest first; guarded by itself.
+    // Resends hold flushLock instead, so they stay in order without blocking the input thread.
+    private final Deque<Outgoing> outbox = new ArrayDeque<>();
+    private final ReentrantLock flushLock = new ReentrantLock();
+    private volatile boolean connected = true;
+    private volatile Runnable unwatchConnection;
+    private volatile Runnable unwatchRoom;
+    private final AtomicBoolean gone = new AtomicBoolean();   // the room was deleted under us
+
+    // When the connection dropped (0 while connected), for the reconnectWindowSeconds countdown
+    private volatile long disconnectedAt;
+    private volatile long lastCountdown;
+
+    // Backoff state for automatic resends of the outbox; reset by a flush that empties it, or a reconnect
+    private final AtomicBoolean retryScheduled = new AtomicBoolean();
+    private volatile int retryAttempt;
+
+    // Client-assigned message IDs that are being sent / have been displayed
+    private final Set<String> pending       = ConcurrentHashMap.newKeySet();
+    private final Set<String> seenClientIds = ConcurrentHashMap.newKeySet();
+
+    // Push keys already shown; polls overlap by a second, so repeats are expected
+    private final Set<String> seenKeys = ConcurrentHashMap.newKeySet();
+
+    // Folded message floods, shown again by /expand <n> (1-based)
+    private final List<List<Message>> collapsed = Collections.synchronizedList(new ArrayList<>());
+
+    // The run of messages from one sender the live batches ended on, so a flood spread over
+    // several polls still folds, and keeps folding into the same block
+    private final FloodRun liveRun = new FloodRun();
+
+    // Messages received while /pause is on; guarded by its own lock together with paused
+    private final List<Message> held = new ArrayList<>();
+    private boolean paused;
+    private int heldMentions;   // held messages that mention you
+
+    // Text awaiting a confirming Enter (confirmSend); only touched on the input thread
+    private String draft;
+
+    // The last message typed and when, to catch a double-tapped Enter; input thread only
+    private String lastTyped;
+    private long   lastTypedAt;
+
+    private record Outgoing(String clientId, String text, long ttlSeconds, String type) {}
+
+    /** A sender's messages within the flood window of the first; block is its collapsed index, or -1. */
+    private static final class FloodRun {
+        String senderId;
+        long   first;
+        int    length;
+        int    block = -1;
+
+        boolean continuedBy(Message msg, long window) {
+            return senderId != null && senderId.equals(msg.getSenderId()) && msg.getTimestamp() - first <= window;
+        }
+
+        void reset() {
+            senderId = null;
+            length   = 0;
+            block    = -1;
+        }
+    }
 
-    public ChatSession(String roomId, UserConfig config, FirebaseClient firebase, Scanner scanner) {
+    // How a send went: REJECTED means the room refused it (observer, broadcast), so resending won't help
+    private enum SendResult { SENT, REJECTED, FAILED }
+
+    public ChatSession(String roomId, UserConfig config, FirebaseClient firebase, Scanner scanner,
+                       CliOptions options) {
         this.roomId = roomId;
         this.config = config;
         this.firebase = firebase;
         this.scanner = scanner;
+        this.options = options;
+        this.alertPattern = compileAlerts(config.getAlertKeywords());
+        this.colors = ColorProfile.detect();
+        this.selfStyle = colors.foreground(config.getSelfColor());
+    }
+
+    private static Pattern compileAlerts(List<String> keywords) {
+        List<String> quoted = new ArrayList<>();
+        for (String k : keywords) {
+            if (k != null && !k.isBlank()) quoted.add(Pattern.quote(k.trim()));
+        }
+        return quoted.isEmpty() ? null : Pattern.compile(String.join("|", quoted), Pattern.CASE_INSENSITIVE);
     }
 
-    public void run() {
-        // Join the room
+    /**
+     * Joins the room and reads input until the session ends. Returns the room to switch
+     * to if it ended with /join, or null.
+     */
+    public String run() {
+        firebase.setSharePresence(config.isSharePresence());
+        firebase.setActivityInterval(config.getActivityIntervalSeconds());
+        if (colors.isLimited()) {
+            System.out.println("[System] This terminal shows a limited palette (" + colors
+                    + "), so colors are approximate. Set COLORTERM=truecolor if it supports more.");
+        }
+
+        // Join the room; spectators watch without registering as a participant
+        boolean elsewhere = false;
+        if (options.readonly) {
+            System.out.println("[System] Spectating (read-only) — you won't appear in the room and can't post.");
+        } else {
+            try {
+                elsewhere = firebase.joinRoom(roomId, config.getUserId(), config.getUsername(), config.getColor(),
+                        config.getJoinDebounceSeconds(), config.getPresenceTimeoutSeconds());
+                joined = true;
+            } catch (Exception e) {
+                System.err.println("Failed to join room: " + e.getMessage());
+                return null;
+            }
+        }
+        if (elsewhere && config.getDuplicateSession().equals(UserConfig.DUPLICATE_BLOCK)) {
+            System.err.println("You appear to be connected to this room elsewhere (duplicateSession is \"block\").");
+            stop();
+            return null;
+        }
+        if (elsewhere) {
+            System.out.println("[System] You appear to be connected elsewhere — you'll show as one participant, "
+                    + "and leave once the last session exits.");
+        }
+
         try {
-            firebase.joinRoom(roomId, config.getUserId(), config.getUsername(), config.getColor());
+            meta = firebase.getRoomMeta(roomId);
         } catch (Exception e) {
-            System.err.println("Failed to join room: " + e.getMessage());
-            return;
+            // Non-fatal — creator-only commands stay unavailable
+        }
+        if (meta != null && meta.getWelcome() != null) printWelcome(meta.getWelcome());
+        if (meta != null && meta.isBroadcast()) {
+            System.out.println("[System] Broadcast room — only the creator can post.");
+        }
+        if (meta != null && meta.getExpiresAt() > 0) {
+            System.out.println("[System] This room expires at "
+                    + new java.util.Date(meta.getExpiresAt() * 1000) + ".");
+            scheduler.scheduleAtFixedRate(this::checkExpiry, 30, 30, TimeUnit.SECONDS);
         }
 
         // Load and display history
         try {
-            List<Message> history = firebase.getInitialMessages(roomId);
-            long maxTs = 0;
+            List<Message> history = new ArrayList<>(firebase.getInitialMessages(roomId, config.getHistoryPageSize()));
+            boolean truncated = history.size() >= config.getHistoryPageSize();
+            if (truncated) {
+                System.out.printf("[System] Showing the latest %d messages — /more loads older ones.%n", history.size());
+            }
+            long oldest = truncated ? history.get(0).getTimestamp() : 0;
+            for (Message event : loadEvents()) {
+                if (recordEvent(event) && config.isEventsInline() && event.getTimestamp() >= oldest) history.add(event);
+            }
+            history.sort(Message.CHRONOLOGICAL);
+            List<Message> fresh = new ArrayList<>();
             for (Message msg : history) {
-                printMessage(msg);
-                if (msg.getTimestamp() > maxTs) maxTs = msg.getTimestamp();
+                advanceCursor(msg);
+                if (addMessage(msg)) fresh.add(msg);
             }
-            lastTimestamp.set(maxTs);
+            printBatch(fresh);
+            markSeen(fresh);
         } catch (Exception e) {
             // Non-fatal — just start with no history
         }
 
-        // Poll for new messages every 500 ms
-        scheduler.scheduleAtFixedRate(this::pollMessages, 500, 500, TimeUnit.MILLISECONDS);
+        // Auto-greeting for bots and status rooms; it comes back like any other message
+        String greeting = sanitize(config.getJoinMessage());
+        if (greeting != null && !greeting.isEmpty() && !options.readonly) sendOrQueue(greeting);
+
+        // Stream new messages; they're shown in batches on the poll schedule (so floods can
+        // still be folded), and the same schedule falls back to polling if the stream drops.
+        // Starting at the oldest message shown also delivers edits to the loaded history.
+        try {
+            unsubscribe = firebase.listenForMessages(roomId, oldestMessageKey(), incoming::add, this::messageChanged,
+                    this::messageRemoved, this::streamFailed);
+        } catch (Exception e) {
+            streamFailed(e);
+        }
+        try {
+            unwatchEvents = firebase.listenForEvents(roomId, lastEventKey.get(), this::eventReceived);
+        } catch (Exception ignored) {
+            // Joins and departures just won't show live
+        }
+        schedulePoll();
+        unwatchConnection = firebase.watchConnection(this::connectionChanged);
+        unwatchRoom = firebase.watchRoom(roomId, () -> roomGone("This room has been deleted.", false));
+        if (config.getReconnectWindowSeconds() > 0) {
+            scheduler.scheduleAtFixedRate(this::checkReconnect, 5, 5, TimeUnit.SECONDS);
+        }
 
-        // Keep-alive heartbeat every 30 s
-        scheduler.scheduleAtFixedRate(
-                () -> {
-                    try { firebase.updateActivity(roomId, config.getUserId()); } catch (Exception ignored) {}
-                },
-                30, 30, TimeUnit.SECONDS
-        );
+        // Presence heartbeat on its own thread so slow polls can't delay it. The same thread
+        // writes coalesced activity once it's due, so all of it stops before leaveRoom runs.
+        if (config.isSharePresence() && !options.readonly) {
+            long beat = config.getHeartbeatSeconds();
+            heartbeat.scheduleAtFixedRate(
+                    () -> {
+                        try { firebase.updateActivity(roomId, config.getUserId()); } catch (Exception ignored) {}
+                    },
+                    beat, beat, TimeUnit.SECONDS
+            );
+            heartbeat.scheduleWithFixedDelay(firebase::flushActivity, 1, 1, TimeUnit.SECONDS);
+        }
 
         // Read input loop (blocking, on main thread)
         while (running.get()) {
+            if (!scanner.hasNextLine()) {
+                // Input closed (end of a pipe, Ctrl+D): leave like /exit
+                stop();
+                return null;
+            }
             String line = scanner.nextLine();
             if (!running.get()) break;
             handleInput(line.trim());
         }
+        return nextRoom;
     }
 
+    /** False once the session has stopped. */
+    public boolean isRunning() {
+        return running.get();
+    }
+
+    /**
+     * Detaches the listeners, stops the background tasks and leaves the room. Safe to call
+     * more than once and from any thread; only the first call does anything.
+     */
     public void stop() {
         if (running.compareAndSet(true, false)) {
-            scheduler.shutdownNow();
-            try { firebase.leaveRoom(roomId, config.getUserId()); } catch (Exception ignored) {}
+            Runnable u = unsubscribe;
+            if (u != null) u.run();
+            Runnable w = unwatchConnection;
+            if (w != null) w.run();
+            Runnable ev = unwatchEvents;
+            if (ev != null) ev.run();
+            Runnable r = unwatchRoom;
+            if (r != null) r.run();
+            // Let an in-flight heartbeat or send finish first, so it can't recreate the participant after we leave
+            shutdown(scheduler, heartbeat, resender, previews, notifier);
+            if (joined) {
+                String parting = partingMessage != null ? partingMessage : config.getLeaveMessage();
+                try { firebase.leaveRoom(roomId, config.getUserId(), sanitize(parting)); } catch (Exception ignored) {}
+            }
         }
     }
 
     // ── private helpers ──────────────────────────────────────────────────────
 
+    private void shutdown(ExecutorService... executors) {
+        for (ExecutorService ex : executors) ex.shutdownNow();
+        // Stopping from one of them (expiry, a lost connection): the others are interrupted,
+        // and waiting would just sit out the timeout on our own thread
+        if (ownThreads.contains(Thread.currentThread())) return;
+        long deadline = System.nanoTime() + TimeUnit.SECONDS.toNanos(2);
+        try {
+            for (ExecutorService ex : executors) {
+                ex.awaitTermination(Math.max(0, deadline - System.nanoTime()), TimeUnit.NANOSECONDS);
+            }
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+        }
+    }
+
+    private void schedulePoll() {
+        if (!running.get()) return;
+        try {
+            scheduler.schedule(() -> {
+                drainStream();
+                if (unsubscribe == null) {
+                    pollMessages();
+                    refreshRecent();
+                }
+                schedulePoll();
+            }, pollMillis.get(), TimeUnit.MILLISECONDS);
+        } catch (RejectedExecutionException ignored) {
+            // Shutting down
+        }
+    }
+
+    private void setPollInterval(String arg) {
+        if (arg.isBlank()) {
+            System.out.printf("[System] Polling every %d ms. Usage: /poll <ms>%n", pollMillis.get());
+            return;
+        }
+        long ms;
+        try {
+            ms = Long.parseLong(arg.trim());
+        } catch (NumberFormatException e) {
+            System.out.println("[System] Usage: /poll <ms> — e.g. /poll 2000 for fewer database reads.");
+            return;
+        }
+        if (ms < MIN_POLL_MS || ms > MAX_POLL_MS) {
+            System.out.printf("[System] Poll interval must be between %d and %d ms.%n", MIN_POLL_MS, MAX_POLL_MS);
+            return;
+        }
+        pollMillis.set(ms);
+        System.out.printf("[System] Polling every %d ms.%n", ms);
+    }
+
+    private List<Message> loadEvents() {
+        try {
+            return firebase.getEvents(roomId, config.getHistoryPageSize());
+        } catch (Exception e) {
+            return List.of();
+        }
+    }
+
+    /** Keeps a room event for /activity; returns false if it was already recorded. */
+    private boolean recordEvent(Message event) {
+        if (event.getKey() == null || !eventKeys.add(event.getKey())) return false;
+        lastEventKey.accumulateAndGet(event.getKey(), ChatSession::laterKey);
+        roomEvents.add(event);
+        return true;
+    }
+
+    /** A live join/leave event; shown in the chat unless eventsInline is off. Runs on the listener thread. */
+    private void eventReceived(Message event) {
+        if (recordEvent(event) && config.isEventsInline()) incoming.add(event);
+    }
+
+    private void streamFailed(Exception e) {
+        Runnable u = unsubscribe;
+        unsubscribe = null;
+        if (u != null) u.run();
+        if (running.get()) System.out.println("[System] Live updates unavailable (" + e.getMessage() + ") — polling instead.");
+    }
+
+    /**
+     * Applies an edit or reaction change to the message shown and reprints it. Runs on the
+     * listener thread, or the poll thread when polling.
+     */
+    private void messageChanged(Message changed) {
+        Message msg;
+        String oldText;
+        Map<String, List<String>> oldReactions;
+        synchronized (messages) {
+            int i = indexOfKey(changed.getKey());
+            if (i < 0) return;
+            msg = messages.get(i);
+            if (msg.isDeleted()) return;
+            oldText      = msg.getText();
+            oldReactions = msg.getReactions();
+            // In place, since a pending expiry and the /pause backlog refer to this object
+            msg.updateFrom(changed);
+        }
+        boolean edited = msg.isEdited() && !msg.getText().equals(oldText);
+        if (!edited && msg.getReactions().equals(oldReactions)) return;
+        synchronized (held) {
+            if (paused) {
+                // An edit is shown again on /resume; reactions show whenever the message next is
+                if (edited && !held.contains(msg)) held.add(msg);
+                return;
+            }
+        }
+        if (edited) {
+            printMessage(msg);
+        } else {
+            String reactions = formatReactions(msg);
+            System.out.printf("[System] Reactions on %s's \"%s\": %s%n", msg.getSender(),
+                    snippet(msg.getText()), reactions.isEmpty() ? "none" : reactions);
+        }
+    }
+
+    /**
+     * Without the live listener nothing reports edits, reactions or deletions, so while
+     * polling the latest page is re-read every REFRESH_MS and compared with what's shown.
+     */
+    private void refreshRecent() {
+        long now = System.currentTimeMillis();
+        if (now - lastRefresh < REFRESH_MS) return;
+        lastRefresh = now;
+
+        int limit = config.getHistoryPageSize();
+        List<Message> recent;
+        try {
+            recent = firebase.getInitialMessages(roomId, limit);
+        } catch (Exception e) {
+            return;
+        }
+        Set<String> present = new HashSet<>();
+        for (Message msg : recent) {
+            present.add(msg.getKey());
+            if (seenKeys.contains(msg.getKey())) messageChanged(msg);
+        }
+        // A full page may have cut off older messages; only ones inside it can be missing
+        String from = recent.size() < limit ? null : recent.get(0).getKey();
+        synchronized (messages) {
+            for (Message msg : messages) {
+                String key = msg.getKey();
+                if (key == null || eventKeys.contains(key) || present.contains(key)) continue;
+                if (msg.isDeleted() || msg.getExpiresAt() > 0) continue;
+                if (from == null || key.compareTo(from) > 0) removals.add(key);
+            }
+        }
+    }
+
+    /** "👍 3  ❤️ 1", or "" without reactions. */
+    private static String formatReactions(Message msg) {
+        List<String> parts = new ArrayList<>();
+        msg.getReactions().forEach((emoji, users) -> parts.add(emoji + " " + users.size()));
+        return String.join("  ", parts);
+    }
+
+    /** Dims text for asides, unless colors are off (NO_COLOR, a dumb terminal). */
+    private String dim(String text) {
+        return colors == ColorProfile.NONE ? text : DIM + text + RESET;
+    }
+
+    private static String snippet(String text) {
+        return text.length() > 30 ? text.substring(0, 29) + "…" : text;
+    }
+
+    /** Queues a message deleted from the room for the next batch. Runs on the listener thread. */
+    private void messageRemoved(String key) {
+        removals.add(key);
+    }
+
+    /**
+     * Marks deleted messages as such; timed messages just expire quietly. One deletion gets
+     * a notice; many at once (a prune, or the room being destroyed) get a single summary.
+     */
+    private void applyRemovals(List<String> keys) {
+        if (gone.get()) return;
+        List<Message> deleted = new ArrayList<>();
+        synchronized (messages) {
+            for (String key : keys) {
+                int i = indexOfKey(key);
+                if (i < 0) continue;
+                Message msg = messages.get(i);
+                if (msg.getExpiresAt() > 0 || msg.isDeleted()) continue;
+                msg.markDeleted();
+                deleted.add(msg);
+            }
+        }
+        if (deleted.size() == 1) {
+            System.out.println(dim("[System] A message from " + deleted.get(0).getSender() + " was deleted."));
+        } else if (deleted.size() > 1) {
+            System.out.println(dim("[System] " + deleted.size() + " messages were deleted."));
+        }
+    }
+
+    /** Position of the message with this push key in messages, or -1. Callers hold the messages lock. */
+    private int indexOfKey(String key) {
+        for (int i = messages.size() - 1; i >= 0; i--) {
+            if (messages.get(i).getKey() != null && messages.get(i).getKey().equals(key)) return i;
+        }
+        return -1;
+    }
+
+    private void drainStream() {
+        List<String> keys = new ArrayList<>();
+        for (String key; (key = removals.poll()) != null; ) keys.add(key);
+        if (!keys.isEmpty()) applyRemovals(keys);
+
+        List<Message> batch = new ArrayList<>();
+        for (Message msg; (msg = incoming.poll()) != null; ) batch.add(msg);
+        if (batch.isEmpty()) return;
+        batch.sort(Message.CHRONOLOGICAL);
+        deliver(batch);
+    }
+
     private void pollMessages() {
         try {
-            List<Message> newMsgs = firebase.pollMessages(roomId, lastTimestamp.get());
+            firebase.pollEvents(roomId, lastEventKey.get(), config.getHistoryPageSize()).forEach(this::eventReceived);
+            String after = lastKey.get();
+            int    limit = config.getMaxHistory();
+            List<Message> page = firebase.pollMessages(roomId, after, limit);
+            // A full page that doesn't start at the cursor skipped whatever came in between
+            if (after != null && page.size() >= limit && !after.equals(page.get(0).getKey())) {
+                System.out.printf("[System] History truncated — more than %d messages arrived since the last update; only the newest are shown.%n", limit);
+            }
+            deliver(page);
+        } catch (Exception ignored) {}
+    }
+
+    /** Moves the poll position past a received chat message; events have a cursor of their own. */
+    private void advanceCursor(Message msg) {
+        if (msg.getKey() != null && !eventKeys.contains(msg.getKey())) lastKey.accumulateAndGet(msg.getKey(), ChatSession::laterKey);
+    }
+
+    /** The later of two push keys (null = none yet); push keys sort in the order they were made. */
+    private static String laterKey(String a, String b) {
+        if (a == null) return b;
+        if (b == null) return a;
+        return a.compareTo(b) >= 0 ? a : b;
+    }
+
+    /** Push key of the oldest chat message shown, or null; events live under keys of their own. */
+    private String oldestMessageKey() {
+        synchronized (messages) {
+            for (Message msg : messages) {
+                if (msg.getKey() != null && !eventKeys.contains(msg.getKey())) return msg.getKey();
+            }
+        }
+        return null;
+    }
+
+    /** Shows newly received messages (dropping ones already seen) and advances the poll position. */
+    private void deliver(List<Message> newMsgs) {
+        try {
+            List<Message> fresh = new ArrayList<>();
             for (Message msg : newMsgs) {
-                printMessage(msg);
-                if (msg.getTimestamp() > lastTimestamp.get()) {
-                    lastTimestamp.set(msg.getTimestamp());
+                advanceCursor(msg);
+                if (!addMessage(msg)) continue;
+                if (isAlert(msg) || mentionsMe(msg)) System.out.print("\007");
+                fresh.add(msg);
+            }
+            synchronized (held) {
+                if (paused) {
+                    held.addAll(fresh);
+                    for (Message msg : fresh) {
+                        if (!mentionsMe(msg)) continue;
+                        heldMentions++;
+                        System.out.printf("[System] %s mentioned you (%d new mention%s) — /resume to read.%n",
+                                msg.getSender(), heldMentions, heldMentions == 1 ? "" : "s");
+                    }
+                    return;
                 }
             }
+            printBatch(fresh);
+            markSeen(fresh);
+            for (Message msg : fresh) previewLinks(msg);
+            for (Message msg : fresh) notifyDesktop(msg);
         } catch (Exception ignored) {}
     }
 
+    /** Fetches the page of history before the oldest message shown and prints it. */
+    private void loadOlder() {
+        String oldest = oldestMessageKey();
+        List<Message> page;
+        try {
+            page = firebase.getMessagesBefore(roomId, oldest, config.getHistoryPageSize());
+        } catch (Exception e) {
+            printError("Failed to load older messages: " + e.getMessage());
+            return;
+        }
+
+        long now = System.currentTimeMillis() / 1000;
+        List<Message> older = new ArrayList<>();
+        for (Message msg : page) {
+            if (msg.getExpiresAt() > 0 && msg.getExpiresAt() <= now) continue;
+            if (msg.getKey() != null && !seenKeys.add(msg.getKey())) continue;
+            older.add(msg);
+        }
+        if (older.isEmpty()) {
+            System.out.println("[System] No older messages.");
+            return;
+        }
+        for (Message msg : older) insertSorted(msg);
+        if (!options.minimal) printRule(String.format("── %d older message%s ──", older.size(), older.size() == 1 ? "" : "s"));
+        printDetached(older);
+        if (!options.minimal) printRule("── end of older messages ──");
+    }
+
+    private void pause() {
+        synchronized (held) {
+            if (paused) {
+                System.out.printf("[System] Paused — %d message%s held. /resume to show them.%n",
+                        held.size(), held.size() == 1 ? "" : "s");
+                return;
+            }
+            paused = true;
+        }
+        System.out.println("[System] Paused — new messages are held until /resume.");
+    }
+
+    private void resume() {
+        List<Message> backlog;
+        synchronized (held) {
+            if (!paused) {
+                System.out.println("[System] Not paused.");
+                return;
+            }
+            // Print while holding the lock so a concurrent poll can't slip in between
+            backlog = new ArrayList<>(held);
+            held.clear();
+            paused = false;
+            System.out.printf("[System] Resumed — %d held message%s%s:%n", backlog.size(), backlog.size() == 1 ? "" : "s",
+                    heldMentions > 0 ? " (" + heldMentions + " new mention" + (heldMentions == 1 ? "" : "s") + ")" : "");
+            heldMentions = 0;
+            printBatch(backlog);
+        }
+        markSeen(backlog);
+    }
+
+    /** Tells the room we've caught up to the newest of these messages (just shown on screen). */
+    private void markSeen(List<Message> shown) {
+        if (shown.isEmpty() || options.readonly) return;
+        long newest = shown.stream().mapToLong(Message::getTimestamp).max().getAsLong();
+        try { firebase.markSeen(roomId, config.getUserId(), newest); } catch (Exception ignored) {}
+    }
+
+    /**
+     * Prints messages that arrived together, folding a flood — floodThreshold or more
+     * from one sender within floodWindowSeconds — into its first message plus a notice.
+     * A flood carried on from the previous live batch folds into the same block.
+     */
+    private void printBatch(List<Message> batch) {
+        printBatch(batch, liveRun);
+    }
+
+    /** Prints the batch with a flood state of its own, for messages from outside the live stream. */
+    private void printDetached(List<Message> batch) {
+        printBatch(batch, new FloodRun());
+    }
+
+    private void printBatch(List<Message> batch, FloodRun run) {
+        // Many failures at once point at a key problem — one banner beats a wall of placeholders
+        long failed = batch.stream().filter(Message::isUndecryptable).count();
+        if (failed > 1) {
+            batch = batch.stream().filter(m -> !m.isUndecryptable()).toList();
+            System.out.printf("[System] %d messages couldn't be decrypted — wrong room key? "
+                    + "They're skipped here; /inspect still shows them.%n", failed);
+        }
+
+        int  threshold = config.getFloodThreshold();
+        long window    = config.getFloodWindowSeconds();
+        synchronized (run) {
+            int i = 0;
+            while (i < batch.size()) {
+                Message first = batch.get(i);
+                if (threshold <= 1 || first.isSystem()) {
+                    run.reset();
+                    printMessage(first);
+                    i++;
+                    continue;
+                }
+                boolean continued = run.continuedBy(first, window);
+                if (!continued) {
+                    run.reset();
+                    run.senderId = first.getSenderId();
+                    run.first    = first.getTimestamp();
+                }
+                int j = i + 1;
+                while (j < batch.size() && run.continuedBy(batch.get(j), window)) j++;
+                run.length += j - i;
+
+                if (run.block >= 0) {
+                    // Already folded: the rest of the flood joins its block
+                    List<Message> block = collapsed.get(run.block);
+                    block.addAll(batch.subList(i, j));
+                    printFoldNotice(first.getSender(), j - i, run.block);
+                } else if (run.length >= threshold) {
+                    // The run's earlier messages are already on screen; fold from here on,
+                    // keeping the first one visible when the whole flood is in this batch
+                    int from = continued ? i : i + 1;
+                    if (!continued) printMessage(first);
+                    collapsed.add(new CopyOnWriteArrayList<>(batch.subList(from, j)));
+                    run.block = collapsed.size() - 1;
+                    printFoldNotice(first.getSender(), j - from, run.block);
+                } else {
+                    for (int k = i; k < j; k++) printMessage(batch.get(k));
+                }
+                i = j;
+            }
+        }
+    }
+
+    private static void printFoldNotice(String sender, int count, int block) {
+        System.out.printf("[System] %s posted %d more message%s — /expand %d to show them.%n",
+                sender, count, count == 1 ? "" : "s", block + 1);
+    }
+
+    private void expand(String arg) {
+        int n;
+        try {
+            n = Integer.parseInt(arg.trim());
+        } catch (NumberFormatException e) {
+            System.out.println("[System] Usage: /expand <n> — n is the number shown in the collapsed notice.");
+            return;
+        }
+        if (n < 1 || n > collapsed.size()) {
+            System.out.println("[System] No collapsed block " + n + ".");
+            return;
+        }
+        for (Message msg : collapsed.get(n - 1)) printMessage(msg);
+    }
+
+    /** Records a received message; returns false for a duplicate of one already shown. */
+    private boolean addMessage(Message msg) {
+        if (msg.getKey() != null && !seenKeys.add(msg.getKey())) return false;
+        if (msg.getClientId() != null && !seenClientIds.add(msg.getClientId())) return false;
+        if (msg.getExpiresAt() > 0) {
+            long left = msg.getExpiresAt() - System.currentTimeMillis() / 1000;
+            scheduleExpiry(msg, Math.max(0, left));
+            if (left <= 0) return false;
+        }
+        insertSorted(msg);
+        return true;
+    }
+
+    /**
+     * Adds a message at its chronological place. Live messages almost always belong at the
+     * end; one that arrives late (a reconnect backfill, an older /more page) is placed by
+     * binary search, so the rest of the list is never re-sorted.
+     */
+    private void insertSorted(Message msg) {
+        synchronized (messages) {
+            insertSorted(messages, msg);
+        }
+    }
+
+    /** Inserts msg into list, which is in CHRONOLOGICAL order, after any message it ties with. */
+    static void insertSorted(List<Message> list, Message msg) {
+        int n = list.size();
+        if (n == 0 || Message.CHRONOLOGICAL.compare(list.get(n - 1), msg) <= 0) {
+            list.add(msg);
+            return;
+        }
+        int at = Collections.binarySearch(list, msg, Message.CHRONOLOGICAL);
+        list.add(at < 0 ? -at - 1 : at + 1, msg);
+    }
+
+    /** Forgets a /timed message when it runs out; the sender's client also deletes it from the room. */
+    private void scheduleExpiry(Message msg, long delaySeconds) {
+        boolean mine = config.getUserId().equals(msg.getSenderId());
+        try {
+            scheduler.schedule(() -> {
+                messages.remove(msg);
+                if (mine && msg.getKey() != null) {
+                    try { firebase.deleteMessage(roomId, config.getUserId(), msg.getKey()); } catch (Exception ignored) {}
+                }
+            }, delaySeconds, TimeUnit.SECONDS);
+        } catch (RejectedExecutionException ignored) {
+            // Shutting down
+        }
+    }
+
+    private void sendTimed(String args) {
+        String[] parts   = args.trim().split("\\s+", 2);
+        long     seconds = 0;
+        if (parts.length == 2) {
+            // A bare number means seconds; 30m, 1h etc. also work
+            String duration = parts[0].matches("\\d+") ? parts[0] + "s" : parts[0];
+            try { seconds = CliOptions.parseDuration(duration); } catch (IllegalArgumentException ignored) {}
+        }
+        if (seconds <= 0) {
+            System.out.println("[System] Usage: /timed <seconds|30m|1h> <text> — the message disappears after that long.");
+            return;
+        }
+        sendOrQueue(parts[1], seconds);
+    }
+
+    /** Ends the session once the room's TTL passes; the creator's client deletes the room. */
+    private void checkExpiry() {
+        RoomMeta m = meta;
+        if (m == null || !m.isExpired(System.currentTimeMillis() / 1000)) return;
+        roomGone("This room has expired.", isCreator());
+    }
+
+    /**
+     * Ends the session for a room that has expired or been deleted, without writing to it
+     * again — a heartbeat or leave notice would recreate it. Every client sees the notice
+     * itself, from its own expiry check or the deletion. With delete, removes the room too.
+     */
+    private void roomGone(String notice, boolean delete) {
+        if (!gone.compareAndSet(false, true)) return;
+        firebase.markClosed(roomId);
+        joined = false;   // nothing to leave
+        System.out.println("[System] " + notice);
+        stop();
+        if (delete) {
+            try { firebase.deleteRoom(roomId); } catch (Exception ignored) {}
+        }
+        System.exit(0);
+    }
+
     private void handleInput(String input) {
-        if (input.isEmpty()) return;
+        if (input.isEmpty()) {
+            // With confirmSend, a bare Enter sends the previewed draft
+            if (draft != null) {
+                sendOrQueue(draft);
+                draft = null;
+            }
+            return;
+        }
+
+        if (options.readonly && !SPECTATOR_COMMANDS.contains(input.split("\\s+", 2)[0].toLowerCase())) {
+            System.out.println("[System] Spectating — only commands that don't change the room work. Type /help.");
+            return;
+        }
 
-        if (input.startsWith("/")) {
-            switch (input.toLowerCase()) {
+        if (input.startsWith("//")) {
+            // Escaped slash: send "/etc/passwd" by typing "//etc/passwd"
+            compose(input.substring(1));
+        } else if (input.startsWith("/")) {
+            String[] parts = input.split("\\s+", 2);
+            String   args  = parts.length > 1 ? parts[1] : "";
+            // /say, /me and /exit take args verbatim; the rest honour "quoted names"
+            List<String> argv = CommandArgs.tokenize(args);
+            switch (parts[0].toLowerCase()) {
                 case "/help" -> printHelp();
                 case "/exit" -> {
+                    if (!args.isBlank()) partingMessage = args;
                     stop();
                     System.exit(0);
                 }
                 case "/clear" -> System.out.print("\033[H\033[2J");
-                default -> System.out.println("[System] Unknown command: " + input + ". Type /help.");
+                case "/say" -> {
+                    if (args.isEmpty()) System.out.println("[System] Usage: /say <text>");
+                    else compose(args);
+                }
+                case "/me" -> {
+                    if (args.isBlank()) System.out.println("[System] Usage: /me <action>, e.g. /me waves");
+                    else sendOrQueue(args.trim(), 0, Message.TYPE_ACTION);
+                }
+                case "/hl" -> {
+                    String text = stripControls(args);
+                    if (text.isEmpty()) System.out.println("[System] Usage: /hl <text> — send a message that stands out in the backlog");
+                    else sendOrQueue(text, 0, Message.TYPE_HIGHLIGHT);
+                }
+                case "/retry" -> retry(args);
+                case "/report" -> reportMessage(argv);
+                case "/inspect" -> inspectMessage(CommandArgs.rest(argv, 0));
+                case "/when" -> showWhen(CommandArgs.rest(argv, 0));
+                case "/goto" -> gotoMessage(CommandArgs.rest(argv, 0));
+                case "/edit" -> editMessage(args);
+                case "/react" -> react(argv);
+                case "/delete" -> deleteMessage(CommandArgs.rest(argv, 0));
+                case "/reports" -> printReports();
+                case "/preview" -> togglePreviews(args);
+                case "/notify" -> toggleNotifications(args);
+                case "/mutenotify" -> toggleNotifyMute(CommandArgs.name(args));
+                case "/timestamps" -> toggleTimestamps(args);
+                case "/ts" -> cycleTimestamps();
+                case "/who" -> printParticipants(args.trim().equalsIgnoreCase("all"));
+                case "/activity" -> printActivity();
+                case "/rooms" -> {
+                    if (argv.isEmpty()) printRooms();
+                    else joinRecent(argv.get(0));
+                }
+                case "/join" -> switchRoom(CommandArgs.name(args));
+                case "/resend" -> resendTo(CommandArgs.rest(argv, 0));
+                case "/nick" -> changeNick(CommandArgs.name(args));
+                case "/find" -> findParticipant(CommandArgs.name(args));
+                case "/promote" -> setRole(CommandArgs.name(args), Participant.MEMBER);
+                case "/demote" -> setRole(CommandArgs.name(args), Participant.OBSERVER);
+                case "/broadcast" -> setBroadcast(args);
+                case "/destroy" -> destroyRoom();
+                case "/security" -> printSecurity();
+                case "/key" -> reenterPassphrase();
+                case "/welcome" -> setWelcome(args);
+                case "/theme" -> setTheme(args);
+                case "/expand" -> expand(args);
+                case "/poll" -> setPollInterval(args);
+                case "/netstats" -> printNetStats();
+                case "/more" -> loadOlder();
+                case "/pause" -> pause();
+                case "/resume" -> resume();
+                case "/timed" -> sendTimed(args);
+                case "/export" -> exportHistory(argv);
+                case "/debug-dump" -> {
+                    if (options.debug) debugDump(args.trim().equals("--include-text"));
+                    else System.out.println("[System] /debug-dump requires starting with --debug.");
+                }
+                case "/debug-mem" -> {
+                    if (options.debug) debugMem();
+                    else System.out.println("[System] /debug-mem requires starting with --debug.");
+                }
+                default -> {
+                    if (config.isStrictCommands()) {
+                        System.out.println("[System] Unknown command: " + input + ". Type /help.");
+                    } else {
+                        compose(input);
+                    }
+                }
             }
         } else {
-            try {
-                firebase.sendMessage(roomId, config.getUserId(), config.getUsername(), config.getColor(), input);
-            } catch (Exception e) {
-                System.err.println("[Error] Failed to send message: " + e.getMessage());
-            }
+            compose(input);
         }
     }
 
-    private void printMessage(Message msg) {
-        String time = new java.text.SimpleDateFormat("HH:mm:ss").format(
-                new java.util.Date(msg.getTimestamp() * 1000));
-        System.out.printf("[%s] %s: %s%n", time, msg.getSender(), msg.getText());
+    /** Sends typed text, or holds it as a draft for a confirming Enter when confirmSend is on. */
+    private void compose(String text) {
+        if (!config.isConfirmSend()) {
+            sendOrQueue(text);
+            return;
+        }
+        draft = text;
+        System.out.println("[Preview] " + text);
+        System.out.println("[System] Press Enter to send, or type a new message to replace it.");
+    }
+
+    /** Sends a message, keeping it in the outbox for /retry if the send fails. */
+    private void sendOrQueue(String text) {
+        sendOrQueue(text, 0);
+    }
+
+    private void sendOrQueue(String text, long ttlSeconds) {
+        sendOrQueue(text, ttlSeconds, null);
+    }
+
+    private void sendOrQueue(String text, long ttlSeconds, String type) {
+        // Retries reuse the clientId, so dedup by ID can't see two presses of Enter
+        String typed = type + ":" + text;
+        long   now   = System.currentTimeMillis();
+        if (typed.equals(lastTyped) && now - lastTypedAt < DOUBLE_SEND_MS) {
+            System.out.println("[System] Not sent again — that's the message you just sent.");
+            return;
+        }
+        lastTyped   = typed;
+        lastTypedAt = now;
+
+        Outgoing out = new Outgoing(UUID.randomUUID().toString(), text, ttlSeconds, type);
+        if (!connected) {
+            // Known to be offline: queue now rather than wait for a send to time out
+            synchronized (outbox) { outbox.addLast(out); }
+            System.out.println("[System] Queued (offline) — it will be sent when the connection is back.");
+            return;
+        }
+        if (send(out) == SendResult.FAILED) {
+            synchronized (outbox) { outbox.addLast(out); }
+            scheduleRetry();
+        }
+    }
+
+    /**
+     * Resends the outbox after a growing delay, saying when — or, once MAX_AUTO_RETRIES
+     * resends in a row have failed, that it's down to /retry.
+     */
+    private void scheduleRetry() {
+        if (retryAttempt >= MAX_AUTO_RETRIES) {
+            System.out.println("[System] Giving up on automatic resends — /retry to try again.");
+            return;
+        }
+        if (!retryScheduled.compareAndSet(false, true)) {
+            System.out.println("[System] Queued — it goes with the resend already scheduled.");
+            return;
+        }
+        long delay = 2L << retryAttempt++;
+        System.out.println("[System] Retrying in " + delay + "s…");
+        try {
+            resender.schedule(() -> {
+                retryScheduled.set(false);
+                // Offline: the reconnect flushes the outbox instead
+                if (connected) resendQueued();
+            }, delay, TimeUnit.SECONDS);
+        } catch (RejectedExecutionException ignored) {
+            retryScheduled.set(false);   // Shutting down
+        }
+    }
+
+    /** Flushes the outbox, backing off for another try if it doesn't empty. Runs on the resender. */
+    private void resendQueued() {
+        if (flushOutbox()) retryAttempt = 0;
+        else scheduleRetry();
+    }
+
+    /** Tracks the database connection; a reconnect flushes the outbox. Runs on the SDK's event thread. */
+    private void connectionChanged(boolean up) {
+        if (connected == up) return;
+        connected = up;
+        if (!up) {
+            disconnectedAt = System.currentTimeMillis();
+            lastCountdown  = 0;
+            long window = config.getReconnectWindowSeconds();
+            System.out.println("[System] Connection lost — messages you send will be queued"
+                    + (window > 0 ? "; giving up in " + formatLeft(window) + "." : "."));
+            return;
+        }
+        disconnectedAt = 0;
+        retryAttempt   = 0;
+        System.out.println("[System] Reconnected.");
+        try {
+            // Sends wait on SDK callbacks, so they can't run on this thread
+            resender.execute(this::resendQueued);
+        } catch (RejectedExecutionException ignored) {
+            // Shutting down
+        }
+    }
+
+    /** Exits once the connection has been down for reconnectWindowSeconds, counting down each minute. */
+    private void checkReconnect() {
+        long since = disconnectedAt;
+        if (since == 0) return;
+        long down = (System.currentTimeMillis() - since) / 1000;
+        long left = config.getReconnectWindowSeconds() - down;
+        if (left <= 0) {
+            int queued;
+            synchronized (outbox) {
+                queued = outbox.size();
+            }
+            System.out.println("[System] Lost connection, exiting."
+                    + (queued > 0 ? " " + queued + " queued message(s) were not sent." : ""));
+            joined = false;   // offline, so a leave notice couldn't be written anyway
+            stop();           // here rather than in the shutdown hook, which would wait on this thread
+            System.exit(1);
+        }
+        if (down / 60 > lastCountdown) {
+            lastCountdown = down / 60;
+            System.out.println("[System] Still reconnecting — giving up in " + formatLeft(left) + ".");
+        }
+    }
+
+    /**
+     * Sends queued messages in the order they were typed; stops at the first failure to keep
+     * that order. Each message stays queued while it's sent, outside the outbox's monitor, so
+     * typing (which queues behind it) never waits on the network. Returns true once it's empty.
+     */
+    private boolean flushOutbox() {
+        flushLock.lock();
+        try {
+            while (true) {
+                Outgoing out;
+                synchronized (outbox) { out = outbox.peekFirst(); }
+                if (out == null) return true;
+                SendResult result = send(out);
+                if (result == SendResult.FAILED) {
+                    int left;
+                    synchronized (outbox) { left = outbox.size(); }
+                    System.out.println("[System] " + left + " message(s) still queued.");
+                    return false;
+                }
+                synchronized (outbox) { outbox.remove(out); }
+                System.out.println((result == SendResult.SENT ? "[System] Resent: " : "[System] Not sent: ") + out.text());
+            }
+        } finally {
+            flushLock.unlock();
+        }
+    }
+
+    /** Sends a message, reporting any failure. Only a FAILED send is worth retrying. */
+    private SendResult send(Outgoing out) {
+        // A "failed" send may have reached the server anyway (e.g. a timeout);
+        // if its ID has shown up in the room, or it's already in flight, don't post it twice
+        if (seenClientIds.contains(out.clientId()) || !pending.add(out.clientId())) return SendResult.SENT;
+        try {
+            firebase.sendMessage(roomId, config.getUserId(), config.getUsername(), config.getColor(),
+                    out.text(), out.clientId(), out.ttlSeconds(), out.type());
+            if (config.getFeedbackMode().equals(UserConfig.FEEDBACK_ALL)) System.out.println("[System] Sent.");
+            return SendResult.SENT;
+        } catch (ReadOnlyException e) {
+            System.out.println("[System] " + e.getMessage());
+            return SendResult.REJECTED;
+        } catch (Exception e) {
+            if (!config.getFeedbackMode().equals(UserConfig.FEEDBACK_NONE)) System.out.print("\007");
+            // The caller says what happens next: an automatic resend, or /retry
+            printError("Failed to send message: " + e.getMessage());
+            return SendResult.FAILED;
+        } finally {
+            pending.remove(out.clientId());
+        }
+    }
+
+    private void retry(String args) {
+        if (!flushLock.tryLock()) {
+            System.out.println("[System] Already resending — the outbox is being flushed.");
+            return;
+        }
+        try {
+            Outgoing out;
+            synchronized (outbox) { out = outbox.peekLast(); }
+            if (out == null) {
+                System.out.println("[System] No failed messages to retry.");
+                return;
+            }
+            if (args.trim().equalsIgnoreCase("all")) {
+                if (flushOutbox()) retryAttempt = 0;
+                return;
+            }
+            SendResult result = send(out);
+            if (result == SendResult.FAILED) return;
+            synchronized (outbox) { outbox.remove(out); }
+            System.out.println((result == SendResult.SENT ? "[System] Resent: " : "[System] Not sent: ") + out.text());
+        } finally {
+            flushLock.unlock();
+        }
+    }
+
+    private void reportMessage(List<String> args) {
+        if (args.isEmpty()) {
+            System.out.println("[System] Usage: /report <n> [reason] — n counts back from the latest message (1 = latest).");
+            return;
+        }
+        Message target = userMessageAt(args.get(0), "report");
+        if (target == null) return;
+
+        String reason = CommandArgs.rest(args, 1);
+        try {
+            firebase.reportMessage(roomId, target.getKey(), config.getUserId(), reason);
+            System.out.println("[System] Reported message from " + target.getSender() + " to the room creator.");
+        } catch (Exception e) {
+            printError("Failed to report message: " + e.getMessage());
+        }
+    }
+
+    private void inspectMessage(String index) {
+        if (index.isEmpty()) {
+            System.out.println("[System] Usage: /inspect <n> — n counts back from the latest message (1 = latest).");
+            return;
+        }
+        Message msg = messageAt(index);
+        if (msg == null) return;
+
+        System.out.println("[System] Message " + index + ":");
+        System.out.println("  sender    : " + msg.getSender());
+        System.out.println("  senderId  : " + msg.getSenderId());
+        System.out.println("  timestamp : " + msg.getTimestamp() + " s ("
+                + Instant.ofEpochSecond(msg.getTimestamp()) + ")");
+        System.out.println("  key       : " + msg.getKey());
+        if (msg.isUnverified()) System.out.println("  verified  : no — sealed without room and sender, so it may have been copied from elsewhere");
+    }
+
+    private void editMessage(String args) {
+        String[] parts = args.trim().split("\\s+", 2);
+        if (parts.length < 2) {
+            System.out.println("[System] Usage: /edit <n> <new text> — n counts back through your own messages (1 = your latest).");
+            return;
+        }
+        Message target = ownMessageAt(parts[0]);
+        if (target == null) return;
+        try {
+            firebase.editMessage(roomId, config.getUserId(), target.getKey(), parts[1]);
+        } catch (Exception e) {
+            printError("Failed to edit message: " + e.getMessage());
+        }
+    }
+
+    /** /react <n> <emoji> adds your reaction to the nth latest message, or takes it back if it's there. */
+    private void react(List<String> args) {
+        if (args.size() != 2) {
+            System.out.println("[System] Usage: /react <n> <emoji> — n counts back from the latest message (1 = latest).");
+            return;
+        }
+        String emoji = args.get(1);
+        // Firebase keys can't hold . $ # [ ] /
+        if (emoji.length() > 16 || !emoji.matches("[^.$#\\[\\]/\\p{Cntrl}\\s]+")) {
+            System.out.println("[System] That can't be used as a reaction.");
+            return;
+        }
+        Message target = userMessageAt(args.get(0), "react to");
+        if (target == null || target.getKey() == null) return;
+
+        boolean mine = target.getReactions().getOrDefault(emoji, List.of()).contains(config.getUserId());
+        try {
+            if (mine) firebase.removeReaction(roomId, target.getKey(), config.getUserId(), emoji);
+            else firebase.addReaction(roomId, target.getKey(), config.getUserId(), emoji);
+        } catch (Exception e) {
+            printError("Failed to update reaction: " + e.getMessage());
+        }
+    }
+
+    private void deleteMessage(String index) {
+        if (index.isEmpty()) {
+            System.out.println("[System] Usage: /delete <n> — n counts back through your own messages (1 = your latest).");
+            return;
+        }
+        Message target = ownMessageAt(index);
+        if (target == null) return;
+        try {
+            firebase.deleteMessage(roomId, config.getUserId(), target.getKey());
+        } catch (Exception e) {
+            printError("Failed to delete message: " + e.getMessage());
+        }
+    }
+
+    /** Reprints the nth latest message with a few messages either side, the target marked with an arrow. */
+    private void gotoMessage(String index) {
+        if (index.isEmpty()) {
+            System.out.println("[System] Usage: /goto <n> — n counts back from the latest message (1 = latest).");
+            return;
+        }
+        Message target = messageAt(index);
+        if (target == null) return;
+
+        List<Message> around;
+        int at;
+        synchronized (messages) {
+            at = messages.indexOf(target);
+            int from = Math.max(0, at - GOTO_CONTEXT);
+            around = new ArrayList<>(messages.subList(from, Math.min(messages.size(), at + GOTO_CONTEXT + 1)));
+            at -= from;
+        }
+        printRule(String.format("── message %s of %d ──", index, messages.size()));
+        for (int i = 0; i < around.size(); i++) {
+            System.out.print(i == at ? "→ " : "  ");
+            printMessage(around.get(i));
+        }
+        printRule("── back to live ──");
+    }
+
+    private void showWhen(String index) {
+        if (index.isEmpty()) {
+            System.out.println("[System] Usage: /when <n> — n counts back from the latest message (1 = latest).");
+            return;
+        }
+        Message msg = messageAt(index);
+        if (msg == null) return;
+
+        String when = java.time.format.DateTimeFormatter.ofPattern("EEEE, d MMMM yyyy 'at' HH:mm:ss zzz")
+                .format(Instant.ofEpochSecond(msg.getTimestamp()).atZone(ZoneId.systemDefault()));
+        System.out.printf("[System] %s's message was sent %s (%s).%n", msg.getSender(), when,
+                formatAgo(System.currentTimeMillis() / 1000 - msg.getTimestamp()));
+    }
+
+    private void printReports() {
+        // Client-side check only; database rules must guard meta/reports for real enforcement
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can view reports.");
+            return;
+        }
+        try {
+            List<Report> reports = firebase.getReports(roomId);
+            if (reports.isEmpty()) {
+                System.out.println("[System] No reports.");
+                return;
+            }
+            System.out.println("[System] Reports:");
+            for (Report r : reports) {
+                Message reported = findMessage(r.getMessageKey());
+                String  about    = reported != null
+                        ? reported.getSender() + ": " + reported.getText()
+                        : "message " + r.getMessageKey();
+                System.out.printf("  [%s] %s reported \"%s\"%s%n", formatTime(r.getTimestamp()),
+                        r.getReporterId(), about, r.getReason().isEmpty() ? "" : " — " + r.getReason());
+            }
+        } catch (Exception e) {
+            printError("Failed to load reports: " + e.getMessage());
+        }
+    }
+
+    private void printParticipants(boolean all) {
+        List<Map.Entry<String, Participant>> participants;
+        try {
+            participants = new ArrayList<>(firebase.getParticipants(roomId).entrySet());
+        } catch (Exception e) {
+            printError("Failed to load participants: " + e.getMessage());
+            return;
+        }
+        participants.sort(Comparator.comparingLong(
+                (Map.Entry<String, Participant> e) -> e.getValue().getLastActive()).reversed());
+
+        long now     = System.currentTimeMillis() / 1000;
+        long timeout = config.getPresenceTimeoutSeconds();
+        long online  = participants.stream().filter(e -> e.getValue().isOnline(now, timeout)).count();
+        int  shown   = all ? participants.size() : Math.min(participants.size(), WHO_LIMIT);
+        System.out.printf("[System] %d in the room, %d online%s:%n", participants.size(), online,
+                othersCaughtUp(participants, now, timeout) ? " — others caught up ✓" : "");
+        for (Map.Entry<String, Participant> entry : participants.subList(0, shown)) {
+            Participant p = entry.getValue();
+            if (entry.getKey().equals(config.getUserId()) && !config.isSharePresence()) {
+                System.out.printf("  ◌ %s (you, presence hidden)%n", p.getName());
+                continue;
+            }
+            System.out.printf("  %s %s%s — active %s%s%n", p.isOnline(now, timeout) ? "●" : "○",
+                    p.getName(), p.isObserver() ? " (observer)" : "", formatAgo(now - p.getLastActive()),
+                    onlineSince(p, now));
+        }
+        if (shown < participants.size()) {
+            System.out.printf("  +%d more (/who all to list everyone)%n", participants.size() - shown);
+        }
+    }
+
+    /** True when every other online participant has seen the newest message shown here. */
+    private boolean othersCaughtUp(List<Map.Entry<String, Participant>> participants, long now, long timeout) {
+        long newest;
+        synchronized (messages) {
+            if (messages.isEmpty()) return false;
+            newest = messages.stream().mapToLong(Message::getTimestamp).max().getAsLong();
+        }
+        boolean anyone = false;
+        for (Map.Entry<String, Participant> e : participants) {
+            if (e.getKey().equals(config.getUserId()) || !e.getValue().isOnline(now, timeout)) continue;
+            if (e.getValue().getLastSeen() < newest) return false;
+            anyone = true;
+        }
+        return anyone;
+    }
+
+    /** Lists recent joins and departures, oldest first, from room events and legacy system notices. */
+    private void printActivity() {
+        List<Message> events = new ArrayList<>();
+        synchronized (roomEvents) {
+            events.addAll(roomEvents);
+        }
+        synchronized (messages) {
+            for (Message msg : messages) {
+                // Events shown inline are already counted; older clients wrote notices into messages
+                if (!msg.isSystem() || eventKeys.contains(msg.getKey())) continue;
+                String t = msg.getText();
+                if (t.endsWith(" joined the room") || t.endsWith(" created the room")
+                        || t.endsWith(" left the room") || t.contains(" left: ")) {
+                    events.add(msg);
+                }
+            }
+        }
+        events.sort(Message.CHRONOLOGICAL);
+        if (events.isEmpty()) {
+            System.out.println("[System] No joins or departures in the loaded history.");
+            return;
+        }
+        List<Message> recent = events.subList(Math.max(0, events.size() - ACTIVITY_LIMIT), events.size());
+        System.out.printf("[System] Recent activity (%d):%n", recent.size());
+        for (Message msg : recent) {
+            System.out.printf("  %s  %s%n", formatTime(msg.getTimestamp()), msg.getText());
+        }
+    }
+
+    /** Shows how many database operations this client has made, to gauge its share of the Firebase bill. */
+    private void printNetStats() {
+        NetStats st = firebase.stats();
+        long minutes = Math.max(1, ManagementFactory.getRuntimeMXBean().getUptime() / 60_000);
+        System.out.printf("[System] Database operations since startup (%d min):%n", minutes);
+        System.out.printf("  reads             %6d  (%.1f/min)%n", st.reads(), (double) st.reads() / minutes);
+        System.out.printf("  writes            %6d  (%.1f/min)%n", st.writes(), (double) st.writes() / minutes);
+        System.out.printf("  messages fetched  %6d%n", st.messagesFetched());
+        System.out.printf("  participant reads %6d%n", st.participantReads());
+        System.out.printf("  messages sent     %6d%n", st.sends());
+        System.out.printf("  presence updates  %6d%n", st.activityUpdates());
+        System.out.printf("  live updates      %6d  (%s)%n", st.liveUpdates(), unsubscribe != null ? "streaming" : "polling");
+    }
+
+    /** Lists the rooms you've recently used, numbered as in `bluelink --list-rooms`. */
+    private void printRooms() {
+        List<String> recent = config.getRecentRooms();
+        if (recent.isEmpty()) {
+            System.out.println("[System] No recent rooms.");
+            return;
+        }
+        System.out.println("[System] Your rooms (most recent first):");
+        for (int i = 0; i < recent.size(); i++) {
+            String id = recent.get(i);
+            String note = id.equals(roomId) ? "  (this room)" : config.getCreatedRooms().contains(id) ? "  (created)" : "";
+            System.out.printf("  %2d. %s%s%n", i + 1, id, note);
+        }
+    }
+
+    /** /resend <room-id>: posts your latest message in this room to another room as well. */
+    private void resendTo(String target) {
+        if (target.isEmpty()) {
+            System.out.println("[System] Usage: /resend <room-id> — posts your latest message there too.");
+            return;
+        }
+        if (target.equals(roomId)) {
+            System.out.println("[System] That's this room — use /say to post it again here.");
+            return;
+        }
+        Message last = ownMessageAt("1");
+        if (last == null) return;
+        try {
+            if (!firebase.checkRoomExists(target)) {
+                System.out.println("[System] Room " + target + " does not exist.");
+                return;
+            }
+            RoomMeta targetMeta = firebase.getRoomMeta(target);
+            String passphrase = null;
+            if (targetMeta != null && targetMeta.isPassphraseProtected()) {
+                passphrase = Main.readSecret("Passphrase for room " + target + ": ", scanner);
+                if (passphrase == null || passphrase.isEmpty()) {
+                    System.out.println("[System] Not posted — room " + target + " needs its passphrase.");
+                    return;
+                }
+            }
+            byte[] secret = config.isGuest() ? null : UserConfig.roomKey(target);
+            firebase.crossPost(target, config.getUserId(), config.getUsername(), config.getColor(),
+                    last.getText(), last.getType(), secret, passphrase);
+            System.out.println("[System] Posted \"" + snippet(last.getText()) + "\" to room " + target + ".");
+        } catch (ReadOnlyException e) {
+            System.out.println("[System] " + e.getMessage());
+        } catch (IllegalArgumentException e) {
+            printError(e.getMessage() + " Not posted.");
+        } catch (Exception e) {
+            printError("Failed to resend to room " + target + ": " + e.getMessage());
+        }
+    }
+
+    /** /rooms <n>: switches to the nth room of the /rooms list. */
+    private void joinRecent(String index) {
+        List<String> recent = config.getRecentRooms();
+        int n;
+        try {
+            n = Integer.parseInt(index);
+        } catch (NumberFormatException e) {
+            n = 0;
+        }
+        if (n < 1 || n > recent.size()) {
+            System.out.println("[System] No room " + index + " in /rooms.");
+            return;
+        }
+        switchRoom(recent.get(n - 1));
+    }
+
+    /** Leaves this room and ends the session so Main can join the other one. */
+    private void switchRoom(String target) {
+        if (target.isEmpty()) {
+            System.out.println("[System] Usage: /join <room-id>");
+            return;
+        }
+        if (target.equals(roomId)) {
+            System.out.println("[System] You're already in room " + roomId + ".");
+            return;
+        }
+        try {
+            if (!firebase.checkRoomExists(target)) {
+                System.out.println("[System] Room " + target + " does not exist.");
+                return;
+            }
+        } catch (Exception e) {
+            printError("Failed to look up room " + target + ": " + e.getMessage());
+            return;
+        }
+        synchronized (outbox) {
+            if (!outbox.isEmpty()) {
+                System.out.println("[System] " + outbox.size() + " unsent message(s) in this room will be dropped.");
+            }
+        }
+        System.out.println("[System] Leaving room " + roomId + "...");
+        nextRoom = target;
+        stop();
+    }
+
+    /** Renames you here and in config.json; the room sees "X is now known as Y". */
+    private void changeNick(String name) {
+        if (name.isEmpty()) {
+            System.out.println("[System] Usage: /nick <new name>");
+            return;
+        }
+        String newName;
+        try {
+            newName = UserConfig.validName(name);
+        } catch (IllegalArgumentException e) {
+            printError(e.getMessage());
+            return;
+        }
+        if (newName.equals(config.getUsername())) {
+            System.out.println("[System] You're already called " + newName + ".");
+            return;
+        }
+        try {
+            config.update(c -> c.setUsername(newName));
+        } catch (Exception e) {
+            printError("Failed to save the new name: " + e.getMessage());
+            return;
+        }
+        try {
+            firebase.updateParticipant(roomId, config.getUserId(), newName, config.getColor());
+            System.out.println("[System] You are now known as " + newName + ".");
+        } catch (Exception e) {
+            printError("Saved, but failed to rename you in this room: " + e.getMessage());
+        }
+    }
+
+    private void findParticipant(String name) {
+        if (name.isEmpty()) {
+            System.out.println("[System] Usage: /find <name>");
+            return;
+        }
+        Map<String, Participant> participants;
+        try {
+            participants = firebase.getParticipants(roomId);
+        } catch (Exception e) {
+            printError("Failed to load participants: " + e.getMessage());
+            return;
+        }
+
+        long now   = System.currentTimeMillis() / 1000;
+        int  found = 0;
+        for (Map.Entry<String, Participant> entry : participants.entrySet()) {
+            Participant p = entry.getValue();
+            if (!p.getName().equalsIgnoreCase(name)) continue;
+            found++;
+            System.out.printf("[System] %s (%s) is here — %s, last active %s at %s%s%n",
+                    p.getName(), entry.getKey(),
+                    p.isOnline(now, config.getPresenceTimeoutSeconds()) ? "online" : "away",
+                    formatAgo(now - p.getLastActive()), formatTime(p.getLastActive()), onlineSince(p, now));
+        }
+        if (found == 0) System.out.println("[System] Nobody named " + name + " is in the room.");
+    }
+
+    /** ", online since 14:03" for someone currently online, or "" when unknown or away. */
+    private String onlineSince(Participant p, long now) {
+        if (p.getSessionStart() <= 0 || !p.isOnline(now, config.getPresenceTimeoutSeconds())) return "";
+        return ", online since " + new java.text.SimpleDateFormat("HH:mm").format(new java.util.Date(p.getSessionStart() * 1000));
+    }
+
+    private void setRole(String name, String role) {
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can change roles.");
+            return;
+        }
+        if (name.isEmpty()) {
+            System.out.println("[System] Usage: /promote <name> or /demote <name>");
+            return;
+        }
+        String userId = resolveParticipant(name);
+        if (userId == null) return;
+        if (userId.equals(config.getUserId())) {
+            System.out.println("[System] The room creator can't change their own role.");
+            return;
+        }
+        try {
+            firebase.setRole(roomId, userId, role);
+            System.out.printf("[System] %s is now %s.%n", name,
+                    Participant.OBSERVER.equals(role) ? "an observer" : "a member");
+        } catch (Exception e) {
+            printError("Failed to change role: " + e.getMessage());
+        }
+    }
+
+    private void setBroadcast(String args) {
+        String mode = args.trim().toLowerCase();
+        if (!mode.equals("on") && !mode.equals("off")) {
+            System.out.println("[System] Usage: /broadcast on|off");
+            return;
+        }
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can change broadcast mode.");
+            return;
+        }
+        try {
+            firebase.setBroadcast(roomId, mode.equals("on"));
+            meta = firebase.getRoomMeta(roomId);
+            System.out.println(mode.equals("on")
+                    ? "[System] Broadcast mode on — only you can post."
+                    : "[System] Broadcast mode off — everyone can post.");
+        } catch (Exception e) {
+            printError("Failed to change broadcast mode: " + e.getMessage());
+        }
+    }
+
+    /** /welcome shows the banner, /welcome off removes it, /welcome <text> sets it ("\n" for new lines). */
+    private void setWelcome(String args) {
+        String text = args.trim();
+        if (text.isEmpty()) {
+            RoomMeta m = meta;
+            if (m == null || m.getWelcome() == null) System.out.println("[System] No welcome banner. Usage: /welcome <text>|off");
+            else printWelcome(m.getWelcome());
+            return;
+        }
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can set the welcome banner.");
+            return;
+        }
+        String welcome = text.equalsIgnoreCase("off") ? null : sanitizeBanner(text.replace("\\n", "\n"));
+        try {
+            firebase.setWelcome(roomId, welcome);
+            meta = firebase.getRoomMeta(roomId);
+            System.out.println(welcome == null ? "[System] Welcome banner removed." : "[System] Welcome banner set.");
+        } catch (Exception e) {
+            printError("Failed to set welcome banner: " + e.getMessage());
+        }
+    }
+
+    /** Like sanitize, but keeps line breaks and allows a longer text. */
+    private static String sanitizeBanner(String text) {
+        String clean = text.replaceAll("[\\p{Cntrl}&&[^\n]]", " ").strip();
+        return clean.length() > MAX_WELCOME ? clean.substring(0, MAX_WELCOME) : clean;
+    }
+
+    private void printWelcome(String welcome) {
+        if (options.minimal) {
+            for (String line : sanitizeBanner(welcome).split("\n")) System.out.println("[Welcome] " + line);
+            return;
+        }
+        String accent = accent();
+        String bar    = accent != null ? accent + "│" + RESET : "│";
+        printRule("┌" + "─".repeat(59));
+        for (String line : sanitizeBanner(welcome).split("\n")) System.out.println(bar + " " + line);
+        printRule("└" + "─".repeat(59));
+    }
+
+    /** The ANSI color for rules and frames: your own theme if set, else the room's, else none. */
+    private String accent() {
+        String local = colors.foreground(config.getTheme());
+        if (local != null) return local;
+        RoomMeta m = meta;
+        return m != null ? colors.foreground(m.getTheme()) : null;
+    }
+
+    private void printRule(String rule) {
+        String accent = accent();
+        System.out.println(accent != null ? accent + rule + RESET : rule);
+    }
+
+    /** /theme [#rrggbb|off]: shows or (creator only) sets the room's accent color. */
+    private void setTheme(String args) {
+        String text = args.trim();
+        RoomMeta m = meta;
+        if (text.isEmpty()) {
+            String theme = m != null ? m.getTheme() : null;
+            System.out.println(theme == null ? "[System] This room has no theme. Usage: /theme <#rrggbb>|off"
+                    : "[System] Room theme: " + theme + (config.getTheme() != null ? " (your own theme " + config.getTheme() + " overrides it)" : ""));
+            return;
+        }
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can set the theme.");
+            return;
+        }
+        String theme;
+        try {
+            theme = text.equalsIgnoreCase("off") ? null : UserConfig.validColor(text);
+        } catch (IllegalArgumentException e) {
+            printError(e.getMessage());
+            return;
+        }
+        try {
+            firebase.setTheme(roomId, theme);
+            meta = firebase.getRoomMeta(roomId);
+            if (theme == null) System.out.println("[System] Room theme removed.");
+            else printRule("── room theme set to " + theme + " ──");
+        } catch (Exception e) {
+            printError("Failed to set theme: " + e.getMessage());
+        }
+    }
+
+    private void destroyRoom() {
+        if (!isCreator()) {
+            System.out.println("[System] Only the room creator can destroy the room.");
+            return;
+        }
+        System.out.printf("Destroy room %s and all its messages for everyone? (y/N): ", roomId);
+        String answer = scanner.hasNextLine() ? scanner.nextLine().trim().toLowerCase() : "";
+        if (!answer.equals("y") && !answer.equals("yes")) {
+            System.out.println("[System] Cancelled.");
+            return;
+        }
+
+        // Stop background writes first so nothing recreates the room after deletion
+        joined = false;
+        gone.set(true);
+        stop();
+        try {
+            firebase.deleteRoom(roomId);
+        } catch (Exception e) {
+            printError("Failed to destroy room: " + e.getMessage());
+            System.exit(1);
+        }
+        // stop() ended the input loop, so run() returns and Main exits
+        System.out.println("Room destroyed.");
+    }
+
+    /**
+     * Asks for the room's passphrase again (without echo) and re-reads the messages no key
+     * could open, for when it was mistyped on joining. A wrong one changes nothing.
+     */
+    private void reenterPassphrase() {
+        RoomMeta m = meta;
+        if (m == null || !m.isPassphraseProtected()) {
+            printError("This room has no passphrase — its key comes from the room ID" + (firebase.hasRoomSecret() ? " and keyfile." : "."));
+            return;
+        }
+        String passphrase = Main.readSecret("Room passphrase: ", scanner);
+        if (passphrase == null || passphrase.isEmpty()) return;
+        try {
+            if (!firebase.usePassphrase(roomId, passphrase)) {
+                printError("Wrong passphrase — still using the previous one.");
+                return;
+            }
+        } catch (Exception e) {
+            printError("Couldn't check the passphrase: " + e.getMessage());
+            return;
+        }
+        List<Message> fixed = new ArrayList<>();
+        int failed = 0;
+        synchronized (messages) {
+            for (Message msg : messages) {
+                if (!msg.isUndecryptable()) continue;
+                if (firebase.redecrypt(msg, roomId)) fixed.add(msg);
+                else failed++;
+            }
+        }
+        if (fixed.isEmpty() && failed == 0) {
+            System.out.println("[System] Passphrase updated.");
+            return;
+        }
+        System.out.printf("[System] Passphrase updated — %d message%s can be read now%s.%n", fixed.size(),
+                fixed.size() == 1 ? "" : "s", failed > 0 ? ", " + failed + " still can't" : "");
+        printDetached(fixed);
+    }
+
+    /** Explains what the room's encryption does and, more importantly, doesn't protect. */
+    private void printSecurity() {
+        RoomMeta m = meta;
+        System.out.println("[System] Encryption: on — AES-256-GCM for every message body.");
+        if (firebase.hasRoomSecret()) {
+            System.out.println("[System] Key: from this room's keyfile (~/.bluelink/keys/" + roomId + ".key)"
+                    + (firebase.hasPassphrase() ? " and passphrase" : "") + ". Only people you've given the key can read what you send.");
+            System.out.println("[System] Messages sent without the keyfile (before it was imported, or by members who don't have it) "
+                    + "use the room-ID key: anyone who knows the room ID can read those.");
+        } else if (firebase.hasPassphrase()) {
+            System.out.println("[System] Key: derived from the passphrase (PBKDF2, salted per room). The passphrase never leaves this machine.");
+        } else {
+            System.out.println("[System] Key: derived from the room ID. Anyone who knows the room ID can read this room.");
+        }
+        System.out.println("[System] Nonce: random per message (older clients used a deterministic one; those still decrypt).");
+        System.out.println("[System] Binding: ciphertexts are tied to room and sender (AAD), so they can't be replayed elsewhere.");
+        System.out.println("[System]   Messages from clients older than that aren't tied to either; they're shown as (unverified).");
+        System.out.println("[System] Not encrypted: names, colors, timestamps, join/leave notices and room metadata.");
+        if (m != null && m.isBroadcast()) {
+            System.out.println("[System] Broadcast mode is enforced by this client only; database rules decide who can really write.");
+        }
+    }
+
+    /** Looks up a participant's user ID by display name (case-insensitive), printing an error if ambiguous or absent. */
+    private String resolveParticipant(String name) {
+        List<String> matches = new ArrayList<>();
+        try {
+            for (Map.Entry<String, Participant> entry : firebase.getParticipants(roomId).entrySet()) {
+                if (entry.getValue().getName().equalsIgnoreCase(name)) matches.add(entry.getKey());
+            }
+        } catch (Exception e) {
+            printError("Failed to load participants: " + e.getMessage());
+            return null;
+        }
+        if (matches.isEmpty()) {
+            System.out.println("[System] Nobody named " + name + " is in the room.");
+            return null;
+        }
+        if (matches.size() > 1) {
+            System.out.println("[System] Several participants are named " + name + ": " + String.join(", ", matches));
+            return null;
+        }
+        return matches.get(0);
+    }
+
+    private void togglePreviews(String args) {
+        String mode = args.trim().toLowerCase();
+        if (!mode.equals("on") && !mode.equals("off")) {
+            if (mode.isEmpty()) System.out.println("[System] Usage: /preview on|off, or /preview <text> to see how a message will look");
+            else previewMessage(args.trim());
+            return;
+        }
+        config.setPreviewEnabled(roomId, mode.equals("on"));
+        saveConfig();
+        if (mode.equals("off")) {
+            System.out.println("[System] Link previews off for this room.");
+        } else if (config.isLinkPreviews()) {
+            System.out.println("[System] Link previews on for this room.");
+        } else {
+            System.out.println("[System] Room opted in, but previews are disabled globally — set \"linkPreviews\": true in ~/.bluelink/config.json.");
+        }
+    }
+
+    private void toggleTimestamps(String args) {
+        String mode = args.trim().toLowerCase();
+        if (!mode.equals("on") && !mode.equals("off")) {
+            System.out.println("[System] Usage: /timestamps on|off");
+            return;
+        }
+        config.setTimestampMode(mode.equals("on") ? UserConfig.TS_ABSOLUTE : UserConfig.TS_HIDDEN);
+        saveConfig();
+        System.out.println("[System] Timestamps " + mode + ".");
+    }
+
+    /** Cycles timestamps: absolute → relative → hidden → absolute. */
+    private void cycleTimestamps() {
+        String next = switch (config.getTimestampMode()) {
+            case UserConfig.TS_ABSOLUTE -> UserConfig.TS_RELATIVE;
+            case UserConfig.TS_RELATIVE -> UserConfig.TS_HIDDEN;
+            default                     -> UserConfig.TS_ABSOLUTE;
+        };
+        config.setTimestampMode(next);
+        saveConfig();
+        System.out.println("[System] Timestamps: " + next + ".");
+    }
+
+    /** Prints text exactly as a sent message would appear (mentions, /me, /hl, timestamps), without sending it. */
+    private void previewMessage(String text) {
+        Message msg = new Message(config.getUsername(), config.getUserId(), config.getColor(), text,
+                System.currentTimeMillis() / 1000);
+        if (text.startsWith("/me ")) {
+            msg.setText(text.substring(4).trim());
+            msg.setType(Message.TYPE_ACTION);
+        } else if (text.startsWith("/hl ")) {
+            msg.setText(text.substring(4).trim());
+            msg.setType(Message.TYPE_HIGHLIGHT);
+        } else if (text.startsWith("//")) {
+            msg.setText(text.substring(1));
+        }
+        System.out.print("[Preview, not sent] ");
+        printMessage(msg);
+        String url = LinkPreview.firstUrl(msg.getText());
+        if (url != null && config.isPreviewEnabled(roomId)) {
+            System.out.println(dim("    ↳ (others with previews on will see the title of " + url + ")"));
+        }
+    }
+
+    /** Fetches and prints the title of the first link in a live message, if this room opted in. */
+    private void previewLinks(Message msg) {
+        if (!config.isPreviewEnabled(roomId) || msg.isSystem()) return;
+        String url = LinkPreview.firstUrl(msg.getText());
+        if (url == null) return;
+        previews.submit(() -> {
+            String title = LinkPreview.fetchTitle(url);
+            if (title != null) System.out.println(dim("    ↳ " + title));
+        });
+    }
+
+    /** Raises a desktop notification for someone else's new message, if notifications are on. */
+    private void notifyDesktop(Message msg) {
+        if (!config.isNotifications() || msg.isSystem() || msg.isUndecryptable()
+                || config.getUserId().equals(msg.getSenderId()) || notifyMuted(msg)) return;
+        String title = "BlueLink · " + msg.getSender();
+        String body  = msg.isAction() ? "* " + msg.getSender() + " " + msg.getText() : msg.getText();
+        try {
+            notifier.submit(() -> {
+                try {
+                    Notifier.send(title, body);
+                } catch (Exception ignored) {
+                    // No notifier on this system — stay quiet
+                }
+            });
+        } catch (RejectedExecutionException ignored) {
+            // Shutting down
+        }
+    }
+
+    private void toggleNotifications(String args) {
+        String mode = args.trim().toLowerCase();
+        if (!mode.equals("on") && !mode.equals("off")) {
+            System.out.println("[System] Usage: /notify on|off (currently " + (config.isNotifications() ? "on" : "off") + ")");
+            return;
+        }
+        config.setNotifications(mode.equals("on"));
+        saveConfig();
+        System.out.println("[System] Desktop notifications " + mode + ".");
+    }
+
+    /** /mutenotify <name>: stops (or restarts) someone's messages notifying you in this room. */
+    private void toggleNotifyMute(String name) {
+        if (name.isEmpty()) {
+            List<String> muted = config.getNotifyMuted(roomId);
+            if (muted.isEmpty()) System.out.println("[System] Usage: /mutenotify <name> — nobody is muted in this room.");
+            else System.out.println("[System] Muted here: " + String.join(", ", mutedNames(muted)) + ". /mutenotify <name> again to unmute.");
+            return;
+        }
+        String userId = resolveParticipant(name);
+        if (userId == null) return;
+        if (userId.equals(config.getUserId())) {
+            System.out.println("[System] Your own messages never notify you.");
+            return;
+        }
+        boolean mute = !config.isNotifyMuted(roomId, userId);
+        config.setNotifyMuted(roomId, userId, mute);
+        saveConfig();
+        System.out.println(mute
+                ? "[System] " + name + "'s messages won't notify, ring or stand out in this room any more."
+                : "[System] " + name + "'s messages notify you again.");
+    }
+
+    /** Display names for muted user IDs, falling back to the ID for anyone no longer in the room. */
+    private List<String> mutedNames(List<String> userIds) {
+        Map<String, Participant> participants;
+        try {
+            participants = firebase.getParticipants(roomId);
+        } catch (Exception e) {
+            participants = Map.of();
+        }
+        List<String> names = new ArrayList<>();
+        for (String id : userIds) {
+            Participant p = participants.get(id);
+            names.add(p != null ? p.getName() : id);
+        }
+        return names;
+    }
+
+    /** True if the sender was muted with /mutenotify in this room. */
+    private boolean notifyMuted(Message msg) {
+        return config.isNotifyMuted(roomId, msg.getSenderId());
+    }
+
+    /** /export <file> [--since <date>] [--until <date>]: writes the shown messages as plain text. */
+    private void exportHistory(List<String> args) {
+        String file  = null;
+        long   since = Long.MIN_VALUE;
+        long   until = Long.MAX_VALUE;
+        try {
+            for (int i = 0; i < args.size(); i++) {
+                String arg = args.get(i);
+                if (arg.equals("--since") || arg.equals("--until")) {
+                    if (i + 1 >= args.size()) throw new IllegalArgumentException(arg + " needs a date");
+                    long t = parseDate(args.get(++i));
+                    if (arg.equals("--since")) since = t; else until = t;
+                } else if (file == null) {
+                    file = arg;
+                } else {
+                    throw new IllegalArgumentException("Unexpected argument: " + arg);
+                }
+            }
+        } catch (IllegalArgumentException e) {
+            System.out.println("[System] " + e.getMessage());
+            return;
+        }
+        if (file == null) {
+            System.out.println("[System] Usage: /export <file> [--since <date>] [--until <date>] — dates like 2024-01-31 or \"2024-01-31 14:00\"");
+            return;
+        }
+        if (since >= until) {
+            System.out.println("[System] --since must be before --until.");
+            return;
+        }
+
+        List<Message> selected = new ArrayList<>();
+        synchronized (messages) {
+            for (Message msg : messages) {
+                if (msg.getTimestamp() >= since && msg.getTimestamp() < until) selected.add(msg);
+            }
+        }
+        Path path = Path.of(file);
+        if (Files.exists(path)) {
+            System.out.printf("%s already exists. Overwrite it? (y/N): ", path);
+            String answer = scanner.hasNextLine() ? scanner.nextLine().trim().toLowerCase() : "";
+            if (!answer.equals("y") && !answer.equals("yes")) {
+                System.out.println("[System] Not exported.");
+                return;
+            }
+        }
+        DateTimeFormatter fmt = DateTimeFormatter.ofPattern("yyyy-MM-dd HH:mm:ss").withZone(ZoneId.systemDefault());
+        try (Writer w = Files.newBufferedWriter(path)) {
+            for (Message msg : selected) {
+                w.write("[" + fmt.format(Instant.ofEpochSecond(msg.getTimestamp())) + "] "
+                        + (msg.isAction() ? "* " + msg.getSender() + " " : msg.getSender() + ": ")
+                        + msg.getText() + System.lineSeparator());
+            }
+            System.out.printf("[System] Exported %d message%s to %s%n", selected.size(), selected.size() == 1 ? "" : "s", path);
+        } catch (Exception e) {
+            printError("Failed to export: " + e.getMessage());
+        }
+    }
+
+    /** Parses 2024-01-31, 2024-01-31 14:00 or 2024-01-31T14:00[:ss] (local time) to epoch seconds. */
+    private static long parseDate(String text) {
+        String t = text.trim().replace(' ', 'T');
+        try {
+            if (t.length() == 10) return java.time.LocalDate.parse(t).atStartOfDay(ZoneId.systemDefault()).toEpochSecond();
+            return java.time.LocalDateTime.parse(t).atZone(ZoneId.systemDefault()).toEpochSecond();
+        } catch (java.time.format.DateTimeParseException e) {
+            throw new IllegalArgumentException("Can't read date \"" + text + "\" — use e.g. 2024-01-31 or \"2024-01-31 14:00\".");
+        }
+    }
+
+    /** Writes session state to ~/.bluelink/debug-dump.json for bug reports; message text is redacted by default. */
+    private void debugDump(boolean includeText) {
+        Map<String, Object> dump = new LinkedHashMap<>();
+        dump.put("roomId", roomId);
+        dump.put("userId", config.getUserId());
+        dump.put("creatorId", meta != null ? meta.getCreatorId() : null);
+        dump.put("running", running.get());
+        dump.put("lastKey", lastKey.get());
+        dump.put("lastEventKey", lastEventKey.get());
+        dump.put("linkPreviews", config.isPreviewEnabled(roomId));
+        dump.put("lastError", lastError);
+        try {
+            dump.put("participants", firebase.getParticipants(roomId));
+        } catch (Exception e) {
+            dump.put("participants", "unavailable: " + e.getMessage());
+        }
+
+        List<Map<String, Object>> dumped = new ArrayList<>();
+        synchronized (messages) {
+            for (Message msg : messages) {
+                Map<String, Object> m = new LinkedHashMap<>();
+                m.put("key", msg.getKey());
+                m.put("senderId", msg.getSenderId());
+                m.put("timestamp", msg.getTimestamp());
+                m.put("text", includeText ? msg.getText() : "<" + msg.getText().length() + " chars redacted>");
+                dumped.add(m);
+            }
+        }
+        dump.put("messageCount", dumped.size());
+        dump.put("messages", dumped);
+
+        Path path = UserConfig.configDir().resolve("debug-dump.json");
+        try (Writer w = Files.newBufferedWriter(path)) {
+            DEBUG_GSON.toJson(dump, w);
+            System.out.println("[System] Wrote " + path);
+        } catch (Exception e) {
+            printError("Failed to write debug dump: " + e.getMessage());
+        }
+    }
+
+    /** Prints thread, heap and task counts — growth across a session hints at a leak. */
+    private void debugMem() {
+        Runtime rt   = Runtime.getRuntime();
+        long    used = rt.totalMemory() - rt.freeMemory();
+        System.out.printf("[System] Threads: %d live (%d daemon)%n",
+                ManagementFactory.getThreadMXBean().getThreadCount(),
+                ManagementFactory.getThreadMXBean().getDaemonThreadCount());
+        System.out.printf("[System] Heap: %d MB used of %d MB allocated (max %d MB)%n",
+                used >> 20, rt.totalMemory() >> 20, rt.maxMemory() >> 20);
+        if (scheduler instanceof ScheduledThreadPoolExecutor pool) {
+            System.out.printf("[System] Scheduled tasks: %d queued, %d running%n",
+                    pool.getQueue().size(), pool.getActiveCount());
+        }
+        int queued;
+        synchronized (outbox) { queued = outbox.size(); }
+        System.out.printf("[System] Messages held: %d, collapsed blocks: %d, outbox: %d%n",
+                messages.size(), collapsed.size(), queued);
+        System.out.printf("[System] Firebase listeners: %d child, %d value; message stream %s, event stream %s%n",
+                firebase.activeChildListeners(), firebase.activeValueListeners(),
+                unsubscribe != null ? "attached" : "detached (polling)",
+                unwatchEvents != null ? "attached" : "detached");
+    }
+
+    private void saveConfig() {
+        try {
+            config.save();
+        } catch (Exception e) {
+            printError("Failed to save config: " + e.getMessage());
+        }
+    }
+
+    private void printError(String message) {
+        lastError = message;
+        System.err.println("[Error] " + message);
+    }
+
+    private boolean isCreator() {
+        RoomMeta m = meta;
+        return m != null && config.getUserId().equals(m.getCreatorId());
+    }
+
+    /** Resolves an index counted back from the newest message (1 = latest), printing an error if invalid. */
+    private Message messageAt(String index) {
+        int n;
+        try {
+            n = Integer.parseInt(index);
+        } catch (NumberFormatException e) {
+            n = 0;
+        }
+        synchronized (messages) {
+            if (n < 1 || n > messages.size()) {
+                System.out.println("[System] No message at index " + index + ". Use 1 for the latest message.");
+                return null;
+            }
+            return messages.get(messages.size() - n);
+        }
+    }
+
+    /** Like messageAt, but refuses join/leave notices, which can't be acted on. */
+    private Message userMessageAt(String index, String action) {
+        Message msg = messageAt(index);
+        if (msg != null && msg.isSystem()) {
+            System.out.println("[System] That's a system notice — you can only " + action + " messages people sent.");
+            return null;
+        }
+        return msg;
+    }
+
+    /** The nth latest message you sent yourself (1 = your latest), or null after printing why. */
+    private Message ownMessageAt(String index) {
+        int n;
+        try {
+            n = Integer.parseInt(index);
+        } catch (NumberFormatException e) {
+            n = 0;
+        }
+        synchronized (messages) {
+            for (int i = messages.size() - 1, seen = 0; i >= 0 && n > 0; i--) {
+                Message msg = messages.get(i);
+                if (!config.getUserId().equals(msg.getSenderId()) || msg.getKey() == null || msg.isDeleted()) continue;
+                if (++seen == n) return msg;
+            }
+        }
+        System.out.println("[System] You have no message at index " + index + ". Use 1 for your latest message.");
+        return null;
+    }
+
+    private Message findMessage(String key) {
+        synchronized (messages) {
+            for (Message msg : messages) {
+                if (key.equals(msg.getKey())) return msg;
+            }
+        }
+        return null;
+    }
+
+    private void printMessage(Message msg) {
+        String prefix = switch (config.getTimestampMode()) {
+            case UserConfig.TS_HIDDEN   -> "";
+            case UserConfig.TS_RELATIVE -> "[" + formatAgo(System.currentTimeMillis() / 1000 - msg.getTimestamp()) + "] ";
+            default                     -> "[" + formatTime(msg.getTimestamp()) + "] ";
+        };
+        if (msg.isDeleted()) {
+            System.out.println(prefix + dim(msg.getSender() + ": " + msg.getText()));
+            return;
+        }
+        String text   = msg.getText();
+        if (isAlert(msg)) {
+            text = alertPattern.matcher(text).replaceAll(m -> HIGHLIGHT + Matcher.quoteReplacement(m.group()) + RESET);
+        }
+        if (!msg.isSystem()) text = highlightMentions(text, !notifyMuted(msg));
+        String sender = msg.getSender();
+        if (selfStyle != null && config.getUserId().equals(msg.getSenderId())) {
+            sender = selfStyle + sender + RESET;
+        }
+        if (msg.isEdited()) text += " (edited)";
+        if (msg.isUnverified()) text += " " + dim("(unverified)");
+        if (msg.getExpiresAt() > 0) {
+            text += "  ⏳ " + formatLeft(msg.getExpiresAt() - System.currentTimeMillis() / 1000);
+        }
+        if (msg.isAction()) {
+            // The whole line in the sender's color, picked back up after each RESET inside it
+            String color = colors.foreground(msg.getColor());
+            String style = color != null ? ITALIC + color : ITALIC;
+            String line  = ("* " + sender + " " + text).replace(RESET, RESET + style);
+            System.out.printf("%s%s%s%s%n", prefix, style, line, RESET);
+        } else if (msg.isHighlight() && notifyMuted(msg)) {
+            // Muted senders don't get to stand out
+            System.out.printf("%s%s: %s%n", prefix, sender, text);
+        } else if (msg.isHighlight() && (colors == ColorProfile.NONE || options.plain)) {
+            // No colors to stand out with, so mark it in text
+            System.out.printf("%s‼ %s: %s%n", prefix, sender, text);
+        } else if (msg.isHighlight()) {
+            // Mentions and the self style end in RESET; pick the accent back up after each
+            String line = (sender + ": " + text).replace(RESET, RESET + ACCENT);
+            System.out.printf("%s%s %s %s%n", prefix, ACCENT, line, RESET);
+        } else {
+            System.out.printf("%s%s: %s%n", prefix, sender, text);
+        }
+        if (!msg.getReactions().isEmpty()) System.out.println("    " + formatReactions(msg));
+    }
+
+    private static String formatLeft(long seconds) {
+        if (seconds < 60)   return Math.max(0, seconds) + "s";
+        if (seconds < 3600) return (seconds / 60) + "m";
+        return (seconds / 3600) + "h";
+    }
+
+    /** True for someone else's message that @mentions you, unless its sender is muted. */
+    private boolean mentionsMe(Message msg) {
+        return !msg.isSystem() && !config.getUserId().equals(msg.getSenderId()) && !notifyMuted(msg)
+                && Mentions.mentions(msg.getText(), config.getUsername());
+    }
+
+    /** Bolds @mentions, and shows ones of you like an alert when loud. */
+    private String highlightMentions(String text, boolean loud) {
+        String me = Mentions.handle(config.getUsername());
+        return Mentions.MENTION.matcher(text).replaceAll(m -> Matcher.quoteReplacement(
+                (loud && m.group(1).equalsIgnoreCase(me) ? HIGHLIGHT : BOLD) + m.group() + RESET));
+    }
+
+    /** True for someone else's message containing one of the configured alert keywords, unless its sender is muted. */
+    private boolean isAlert(Message msg) {
+        return alertPattern != null && !msg.isSystem()
+                && !config.getUserId().equals(msg.getSenderId()) && !notifyMuted(msg)
+                && alertPattern.matcher(msg.getText()).find();
+    }
+
+    /** Strips control characters and caps length for text that others will see. */
+    private static String sanitize(String text) {
+        String clean = stripControls(text);
+        return clean != null && clean.length() > MAX_NOTICE ? clean.substring(0, MAX_NOTICE) : clean;
+    }
+
+    /** Like sanitize, without the length cap, for message bodies. */
+    private static String stripControls(String text) {
+        return text == null ? null : text.replaceAll("\\p{Cntrl}", " ").trim();
+    }
+
+    private static String formatAgo(long seconds) {
+        if (seconds < 60)    return "just now";
+        if (seconds < 3600)  return (seconds / 60) + "m ago";
+        if (seconds < 86400) return (seconds / 3600) + "h ago";
+        return (seconds / 86400) + "d ago";
+    }
+
+    private static String formatTime(long epochSeconds) {
+        return new java.text.SimpleDateFormat("HH:mm:ss").format(new java.util.Date(epochSeconds * 1000));
     }
 
     private void printHelp() {
         System.out.println("""
                 Commands:
-                  /help   — show this help
-                  /clear  — clear the screen
-                  /exit   — leave the room and quit
+                  /help                — show this help
+                  /clear               — clear the screen
+                  /say <text>          — send text verbatim, even if it starts with /
+                  //text               — same as /say /text
+                  /me <action>         — send an action, shown as "* Name waves"
+                  /hl <text>           — send a message highlighted so it stands out
+                  /timed <secs> <text> — send a message that disappears after that long
+                  /react <n> <emoji>   — react to the nth latest message (again to take it back)
+                  /edit <n> <text>     — replace the text of your nth latest message
+                  /delete <n>          — delete your nth latest message for everyone
+                  /retry [all]         — resend the last failed message (or all of them)
+                  /report <n> [reason] — flag the nth latest message to the room creator
+                  /reports             — list reported messages (creator only)
+                  /export <file> ...   — save shown messages as text; --since/--until <date> narrow it
+                  /inspect <n>         — show the nth latest message's metadata
+                  /when <n>            — show the full date and time of the nth latest message
+                  /goto <n>            — show the nth latest message again, with the messages around it
+                  /expand <n>          — show the messages folded into collapsed block n
+                  /preview on|off      — show page titles for links in this room
+                  /preview <text>      — show how a message will look, without sending it
+                  /notify on|off       — desktop notifications for new messages
+                  /mutenotify <name>   — stop (or restart) someone's messages notifying you here
+                  /timestamps on|off   — show or hide message times
+                  /ts                  — cycle times: absolute, relative, hidden
+                  /poll [ms]           — show or change how often new messages are shown
+                  /netstats            — count the database reads and writes made so far
+                  /more                — load the page of history before the oldest shown message
+                  /pause, /resume      — hold incoming messages while you read, then show them
+                  /who [all]           — list participants (first 20 unless "all")
+                  /activity            — list recent joins and departures
+                  /rooms [n]           — list the rooms you've recently used, or switch to the nth
+                  /join <room-id>      — leave this room and join another
+                  /resend <room-id>    — post your latest message to another room too
+                  /find <name>         — check whether someone is in the room
+                  /nick <name>         — change your display name (here and in your config)
+                  /promote <name>      — let an observer post again (creator only)
+                  /demote <name>       — make a participant a read-only observer (creator only)
+                  /broadcast on|off    — only the creator can post while on (creator only)
+                  /welcome [text|off]  — show or set the banner joiners see first (creator only)
+                  /theme [#rrggbb|off] — show or set the room's accent color (creator only)
+                  /destroy             — delete the room for everyone (creator only)
+                  /security            — explain what this room's encryption protects
+                  /key                 — re-enter the room's passphrase and re-read what failed
+                  /exit [message]      — leave the room (with an optional parting message) and quit
                 """);
     }
 }
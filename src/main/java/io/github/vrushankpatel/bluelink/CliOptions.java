@@ -0,0 +1,126 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * Command-line arguments: an optional room ID plus flags.
+ *
+ * Usage: bluelink [room-id] [--debug] [--guest] [--new] [--minimal] [--plain] [--readonly] [--passphrase] [--ttl <duration>] [--import <file.json>]
+ *        bluelink rooms
+ *        bluelink --list-rooms
+ *        bluelink --prune <room-id> <days>
+ *        bluelink --export-key <room-id>
+ *        bluelink --import-key <room-id> <base64>
+ *        bluelink [--set-name <name>] [--set-color <#rrggbb>]
+ */
+final class CliOptions {
+
+    private static final Pattern DURATION = Pattern.compile("(\\d+)([smhd])");
+
+    String  roomId;
+    boolean debug;
+    long    ttlSeconds;   // lifetime of a newly created room, 0 = forever
+    String  importFile;   // import this JSON history into roomId and exit
+    boolean newRoom;      // create a fresh room even if defaultRoom is set
+    boolean passphrase;   // ask for a passphrase for the room being created (never taken from argv, which other users can see)
+    boolean guest;        // throwaway identity, nothing read from or written to ~/.bluelink
+    boolean listRooms;    // `bluelink rooms`: print the rooms you've used and exit
+    boolean pickRoom;     // --list-rooms: print them and join the one picked by number
+    boolean readonly;     // spectate: no participant record, no posting, no leave notice
+    boolean plain;        // no ANSI escapes or bell at all (implies minimal), for scripts and screen readers
+    boolean minimal;      // no banner, rules or boxes — just message lines, for embedding or tiny terminals
+    String  exportKeyRoom; // print this room's keyfile secret (creating one if needed) and exit
+    String  importKeyRoom; // store importKey as this room's keyfile and exit
+    String  importKey;     // base64 secret for --import-key
+    String  pruneRoom;     // delete this room's messages older than pruneDays and exit
+    int     pruneDays;
+    String  setName;       // save a new display name to config.json and exit
+    String  setColor;      // save a new name color to config.json and exit
+
+    private CliOptions() {}
+
+    static CliOptions parse(String[] args) {
+        CliOptions opts = new CliOptions();
+        if (args.length > 0 && args[0].equals("rooms")) {
+            if (args.length > 1) throw new IllegalArgumentException("Unexpected argument: " + args[1]);
+            opts.listRooms = true;
+            return opts;
+        }
+        for (int i = 0; i < args.length; i++) {
+            String arg = args[i];
+            switch (arg) {
+                case "--debug" -> opts.debug = true;
+                case "--guest" -> opts.guest = true;
+                case "--new" -> opts.newRoom = true;
+                case "--minimal" -> opts.minimal = true;
+                case "--plain" -> {
+                    opts.plain   = true;
+                    opts.minimal = true;
+                }
+                case "--readonly" -> opts.readonly = true;
+                case "--list-rooms" -> opts.pickRoom = true;
+                case "--passphrase" -> opts.passphrase = true;
+                case "--ttl" -> opts.ttlSeconds = parseDuration(value(args, ++i, arg));
+                case "--import" -> opts.importFile = value(args, ++i, arg);
+                case "--prune" -> {
+                    opts.pruneRoom = value(args, ++i, arg);
+                    opts.pruneDays = parseDays(value(args, ++i, arg));
+                }
+                case "--set-name" -> opts.setName = value(args, ++i, arg);
+                case "--set-color" -> opts.setColor = value(args, ++i, arg);
+                case "--export-key" -> opts.exportKeyRoom = value(args, ++i, arg);
+                case "--import-key" -> {
+                    opts.importKeyRoom = value(args, ++i, arg);
+                    opts.importKey = value(args, ++i, arg);
+                }
+                default -> {
+                    if (arg.startsWith("-")) throw new IllegalArgumentException("Unknown option: " + arg);
+                    if (opts.roomId != null) throw new IllegalArgumentException("Unexpected argument: " + arg);
+                    opts.roomId = arg;
+                }
+            }
+        }
+        if (opts.pickRoom && (opts.roomId != null || opts.newRoom)) {
+            throw new IllegalArgumentException("--list-rooms picks the room; don't pass a room ID or --new with it");
+        }
+        if (opts.newRoom && opts.roomId != null) {
+            throw new IllegalArgumentException("--new creates a room; don't pass a room ID with it");
+        }
+        if ((opts.setName != null || opts.setColor != null) && (opts.guest || opts.roomId != null)) {
+            throw new IllegalArgumentException("--set-name and --set-color only update your saved config; run them on their own");
+        }
+        if (opts.importFile != null && opts.roomId == null) {
+            throw new IllegalArgumentException("--import needs the room ID to import into");
+        }
+        return opts;
+    }
+
+    private static String value(String[] args, int i, String flag) {
+        if (i >= args.length) throw new IllegalArgumentException(flag + " needs a value");
+        return args[i];
+    }
+
+    private static int parseDays(String text) {
+        try {
+            int days = Integer.parseInt(text.trim());
+            if (days > 0) return days;
+        } catch (NumberFormatException ignored) {
+            // Reported below
+        }
+        throw new IllegalArgumentException("--prune needs a whole number of days, e.g. --prune 12345678 30");
+    }
+
+    /** Parses durations like 90s, 30m, 24h or 7d into seconds. */
+    static long parseDuration(String text) {
+        Matcher m = DURATION.matcher(text.trim().toLowerCase());
+        if (!m.matches()) throw new IllegalArgumentException("Invalid duration: " + text + " (use e.g. 30m, 24h, 7d)");
+        long n = Long.parseLong(m.group(1));
+        return switch (m.group(2)) {
+            case "s" -> n;
+            case "m" -> n * 60;
+            case "h" -> n * 3600;
+            default  -> n * 86400;
+        };
+    }
+}
@@ -0,0 +1,35 @@
+package io.github.vrushankpatel.bluelink;
+
+/**
+ * How many colors the terminal can show, guessed from the environment, and how to
+ * map a hex color onto them. Limited palettes map deterministically so a given
+ * color always lands on the same approximation.
+ */
+enum ColorProfile {
+    TRUECOLOR, ANSI256, ANSI16, NONE;
+
+    static ColorProfile detect() {
+        String term      = System.getenv().getOrDefault("TERM", "");
+        String colorterm = System.getenv().getOrDefault("COLORTERM", "").toLowerCase();
+        if (System.getenv("NO_COLOR") != null || term.equals("dumb")) return NONE;
+        if (colorterm.equals("truecolor") || colorterm.equals("24bit")) return TRUECOLOR;
+        if (term.contains("256color")) return ANSI256;
+        return ANSI16;
+    }
+
+    boolean isLimited() { return this == ANSI256 || this == ANSI16; }
+
+    /** Foreground escape for "#rrggbb", or null if malformed or colors are off. */
+    String foreground(String hex) {
+        if (this == NONE || hex == null || !hex.matches("#?[0-9a-fA-F]{6}")) return null;
+        int rgb = Integer.parseInt(hex.startsWith("#") ? hex.substring(1) : hex, 16);
+        int r = rgb >> 16, g = (rgb >> 8) & 0xff, b = rgb & 0xff;
+        return switch (this) {
+            case TRUECOLOR -> "\033[38;2;" + r + ";" + g + ";" + b + "m";
+            // 6×6×6 color cube starting at index 16
+            case ANSI256   -> "\033[38;5;" + (16 + 36 * (r * 5 / 255) + 6 * (g * 5 / 255) + b * 5 / 255) + "m";
+            // Bright black..white: one bit per channel that's more than half on
+            default        -> "\033[" + (90 + (r > 127 ? 1 : 0) + (g > 127 ? 2 : 0) + (b > 127 ? 4 : 0)) + "m";
+        };
+    }
+}
@@ -0,0 +1,58 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.util.ArrayList;
+import java.util.List;
+
+/**
+ * Splits slash-command arguments on whitespace, keeping "double-quoted" sections
+ * together so names with spaces work: /find "Bob Smith".
+ *
+ * Only double quotes group — apostrophes in names like O'Brien stay literal.
+ * An unmatched quote runs to the end of the input rather than failing.
+ */
+final class CommandArgs {
+
+    private CommandArgs() {}
+
+    static List<String> tokenize(String input) {
+        List<String>  tokens  = new ArrayList<>();
+        StringBuilder current = new StringBuilder();
+        boolean inToken = false;
+        boolean quoted  = false;
+
+        for (int i = 0; i < input.length(); i++) {
+            char c = input.charAt(i);
+            if (c == '"') {
+                quoted  = !quoted;
+                inToken = true;   // "" is an (empty) argument
+            } else if (Character.isWhitespace(c) && !quoted) {
+                if (inToken) {
+                    tokens.add(current.toString());
+                    current.setLength(0);
+                    inToken = false;
+                }
+            } else {
+                current.append(c);
+                inToken = true;
+            }
+        }
+        if (inToken) tokens.add(current.toString());
+        return tokens;
+    }
+
+    /**
+     * Reads a command's single name argument: the quoted section as typed when the input starts
+     * with a quote ("Bob  Smith" keeps both spaces, anything after it is ignored), otherwise
+     * the words joined with single spaces, so /find Bob Smith works unquoted too.
+     */
+    static String name(String input) {
+        List<String> tokens = tokenize(input);
+        if (input.strip().startsWith("\"")) return tokens.get(0);
+        return rest(tokens, 0);
+    }
+
+    /** Joins tokens from index start onward with single spaces ("" if none). */
+    static String rest(List<String> tokens, int start) {
+        return start >= tokens.size() ? "" : String.join(" ", tokens.subList(start, tokens.size()));
+    }
+}
@@ -0,0 +1,68 @@
+package io.github.vrushankpatel.bluelink;
+
+import com.google.gson.JsonElement;
+import com.google.gson.JsonObject;
+import com.google.gson.JsonParser;
+import io.github.vrushankpatel.bluelink.config.UserConfig;
+import io.github.vrushankpatel.bluelink.firebase.FirebaseClient;
+
+import java.io.Reader;
+import java.nio.file.Files;
+import java.nio.file.Path;
+
+/**
+ * Seeds a room from a JSON export: an array of {"sender", "text", "timestamp"} objects.
+ *
+ * Messages are re-encrypted for the target room and posted as the importing user,
+ * with the original sender noted in the text and the original timestamp kept.
+ */
+final class HistoryImport {
+
+    private HistoryImport() {}
+
+    static void run(Path file, String roomId, UserConfig config, FirebaseClient firebase) throws Exception {
+        JsonElement root;
+        try (Reader r = Files.newBufferedReader(file)) {
+            root = JsonParser.parseReader(r);
+        }
+        if (!root.isJsonArray()) {
+            throw new IllegalArgumentException(file + " must contain a JSON array of messages.");
+        }
+
+        int imported = 0;
+        int skipped  = 0;
+        for (JsonElement el : root.getAsJsonArray()) {
+            JsonObject obj = el.isJsonObject() ? el.getAsJsonObject() : null;
+            if (obj == null || !isString(obj, "text") || !isNumber(obj, "timestamp")) {
+                skipped++;
+                continue;
+            }
+            String text      = sanitize(obj.get("text").getAsString());
+            long   timestamp = obj.get("timestamp").getAsLong();
+            String sender    = isString(obj, "sender") ? sanitize(obj.get("sender").getAsString()) : "";
+            if (text.isEmpty() || timestamp <= 0) {
+                skipped++;
+                continue;
+            }
+
+            String note = sender.isEmpty() ? " (imported)" : " (imported from " + sender + ")";
+            firebase.importMessage(roomId, config.getUserId(), config.getUsername(), config.getColor(),
+                    text + note, timestamp);
+            imported++;
+        }
+        System.out.printf("Imported %d message(s) into room %s, skipped %d malformed.%n", imported, roomId, skipped);
+    }
+
+    private static boolean isString(JsonObject obj, String field) {
+        return obj.has(field) && obj.get(field).isJsonPrimitive() && obj.get(field).getAsJsonPrimitive().isString();
+    }
+
+    private static boolean isNumber(JsonObject obj, String field) {
+        return obj.has(field) && obj.get(field).isJsonPrimitive() && obj.get(field).getAsJsonPrimitive().isNumber();
+    }
+
+    // Imported text is untrusted — keep terminal control sequences out of everyone's screen
+    private static String sanitize(String text) {
+        return text.replaceAll("\\p{Cntrl}", " ").trim();
+    }
+}
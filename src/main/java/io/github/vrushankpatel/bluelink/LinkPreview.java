@@ -0,0 +1,165 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.io.ByteArrayOutputStream;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.net.Inet6Address;
+import java.net.InetAddress;
+import java.net.InetSocketAddress;
+import java.net.Socket;
+import java.net.SocketTimeoutException;
+import java.net.URI;
+import java.nio.charset.StandardCharsets;
+import java.time.Duration;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+import javax.net.ssl.SSLParameters;
+import javax.net.ssl.SSLSocket;
+import javax.net.ssl.SSLSocketFactory;
+
+/**
+ * Fetches page titles for URLs in messages.
+ *
+ * Fetches are bounded (3 s in all, first 64 KB of the body), never follow
+ * redirects, and refuse hosts that resolve to loopback, private or link-local
+ * addresses so a message can't make the client probe the local network. The
+ * connection goes to the address that was checked, so a second DNS answer
+ * can't swap in a local one; TLS is still verified against the host name.
+ */
+final class LinkPreview {
+
+    private static final Pattern  URL        = Pattern.compile("https?://[^\\s<>\"]+");
+    private static final Pattern  TITLE      = Pattern.compile("<title[^>]*>(.*?)</title>",
+                                                   Pattern.CASE_INSENSITIVE | Pattern.DOTALL);
+    private static final Pattern  STATUS_OK  = Pattern.compile("HTTP/1\\.[01] 200\\b.*");
+    private static final int      MAX_BYTES  = 64 * 1024;
+    private static final int      MAX_HEADER = 16 * 1024;
+    private static final int      MAX_TITLE  = 120;
+    private static final Duration TIMEOUT    = Duration.ofSeconds(3);
+
+    private LinkPreview() {}
+
+    /** Returns the first http(s) URL in the text, or null. */
+    static String firstUrl(String text) {
+        Matcher m = URL.matcher(text);
+        return m.find() ? m.group() : null;
+    }
+
+    /** Returns the page's title, or null if it can't be fetched safely. */
+    static String fetchTitle(String url) {
+        long deadline = System.nanoTime() + TIMEOUT.toNanos();
+        try {
+            URI uri = URI.create(url);
+            if (uri.getHost() == null) return null;
+            String host = uri.getHost().replaceAll("^\\[|]$", "");   // IPv6 literals come bracketed
+            InetAddress addr = publicAddress(host);
+            if (addr == null) return null;
+
+            boolean https = "https".equalsIgnoreCase(uri.getScheme());
+            int     port  = uri.getPort() != -1 ? uri.getPort() : https ? 443 : 80;
+            try (Socket socket = connect(addr, host, port, https, deadline)) {
+                OutputStream out = socket.getOutputStream();
+                out.write(request(uri).getBytes(StandardCharsets.ISO_8859_1));
+                out.flush();
+
+                byte[] response = readUntil(socket, deadline);
+                String head     = new String(response, StandardCharsets.ISO_8859_1);
+                int    bodyAt   = head.indexOf("\r\n\r\n");
+                if (bodyAt < 0 || !STATUS_OK.matcher(head.substring(0, head.indexOf("\r\n"))).matches()) return null;
+
+                String  html = new String(response, bodyAt + 4, response.length - bodyAt - 4, StandardCharsets.UTF_8);
+                Matcher m    = TITLE.matcher(html);
+                if (!m.find()) return null;
+                return clean(m.group(1));
+            }
+        } catch (Exception e) {
+            return null;
+        }
+    }
+
+    /** The host's first address, or null if any of its addresses isn't public. */
+    private static InetAddress publicAddress(String host) throws Exception {
+        InetAddress[] addrs = InetAddress.getAllByName(host);
+        for (InetAddress addr : addrs) {
+            if (addr.isLoopbackAddress() || addr.isAnyLocalAddress() || addr.isSiteLocalAddress()
+                    || addr.isLinkLocalAddress() || addr.isMulticastAddress()) {
+                return null;
+            }
+            // IPv6 unique local addresses (fc00::/7) aren't covered by isSiteLocalAddress
+            if (addr instanceof Inet6Address && (addr.getAddress()[0] & 0xFE) == 0xFC) return null;
+        }
+        return addrs.length > 0 ? addrs[0] : null;
+    }
+
+    private static Socket connect(InetAddress addr, String host, int port, boolean https, long deadline) throws Exception {
+        Socket socket = new Socket();
+        try {
+            socket.connect(new InetSocketAddress(addr, port), remainingMillis(deadline));
+            if (!https) return socket;
+
+            // Layered over the socket to addr; the host name is only used for SNI and certificate checks
+            SSLSocket tls = (SSLSocket) ((SSLSocketFactory) SSLSocketFactory.getDefault())
+                    .createSocket(socket, host, port, true);
+            SSLParameters params = tls.getSSLParameters();
+            params.setEndpointIdentificationAlgorithm("HTTPS");
+            tls.setSSLParameters(params);
+            tls.setSoTimeout(remainingMillis(deadline));
+            tls.startHandshake();
+            return tls;
+        } catch (Exception e) {
+            socket.close();
+            throw e;
+        }
+    }
+
+    /** A plain HTTP/1.0 GET, so the body comes back unchunked and the server closes when done. */
+    private static String request(URI uri) {
+        String path = uri.getRawPath() == null || uri.getRawPath().isEmpty() ? "/" : uri.getRawPath();
+        if (uri.getRawQuery() != null) path += "?" + uri.getRawQuery();
+        String host = uri.getPort() != -1 ? uri.getHost() + ":" + uri.getPort() : uri.getHost();
+        return "GET " + path + " HTTP/1.0\r\n"
+                + "Host: " + host + "\r\n"
+                + "User-Agent: bluelink-preview\r\n"
+                + "Accept: text/html\r\n"
+                + "Connection: close\r\n\r\n";
+    }
+
+    /**
+     * Reads the response up to the size cap, stopping at the deadline however slowly it
+     * trickles in; what arrived by then is returned, since the title is usually near the top.
+     */
+    private static byte[] readUntil(Socket socket, long deadline) throws Exception {
+        InputStream           in   = socket.getInputStream();
+        ByteArrayOutputStream data = new ByteArrayOutputStream();
+        byte[] buf = new byte[8192];
+        try {
+            while (data.size() < MAX_HEADER + MAX_BYTES) {
+                socket.setSoTimeout(remainingMillis(deadline));
+                int n = in.read(buf, 0, Math.min(buf.length, MAX_HEADER + MAX_BYTES - data.size()));
+                if (n < 0) break;
+                data.write(buf, 0, n);
+            }
+        } catch (SocketTimeoutException e) {
+            // out of time: go with what we have
+        }
+        return data.toByteArray();
+    }
+
+    /** Milliseconds left before the deadline; throws once it has passed (0 would mean no timeout). */
+    private static int remainingMillis(long deadline) throws SocketTimeoutException {
+        long left = (deadline - System.nanoTime()) / 1_000_000;
+        if (left <= 0) throw new SocketTimeoutException("preview took too long");
+        return (int) left;
+    }
+
+    private static String clean(String raw) {
+        String title = raw
+                .replace("&lt;", "<").replace("&gt;", ">").replace("&quot;", "\"")
+                .replace("&#39;", "'").replace("&amp;", "&")
+                .replaceAll("\\p{Cntrl}", " ")   // never pass terminal escapes through
+                .replaceAll("\\s+", " ")
+                .trim();
+        if (title.isEmpty()) return null;
+        return title.length() > MAX_TITLE ? title.substring(0, MAX_TITLE) + "…" : title;
+    }
+}
@@ -2,51 +2,286 @@ package io.github.vrushankpatel.bluelink;
 
 import io.github.vrushankpatel.bluelink.config.UserConfig;
 import io.github.vrushankpatel.bluelink.firebase.FirebaseClient;
+import io.github.vrushankpatel.bluelink.firebase.RoomMeta;
 
+import java.io.Console;
+import java.nio.file.Paths;
+import java.util.Base64;
 import java.util.Scanner;
+import java.util.concurrent.atomic.AtomicReference;
 
 public class Main {
 
+    // --prune never deletes the newest messages, however old, so a quiet room isn't emptied
+    private static final int PRUNE_KEEP = 10;
+
+    // Tries at a passphrase prompt before giving up
+    private static final int PASSPHRASE_ATTEMPTS = 3;
+
     public static void main(String[] args) throws Exception {
-        printBanner();
+        CliOptions options;
+        try {
+            options = CliOptions.parse(args);
+        } catch (IllegalArgumentException e) {
+            System.err.println(e.getMessage());
+            System.err.println("Usage: java -jar bluelink.jar [room-id] [--debug] [--guest] [--new] [--minimal] [--plain] [--readonly] [--passphrase] [--ttl <duration>] [--import <file.json>]");
+            System.err.println("       java -jar bluelink.jar rooms | --list-rooms");
+            System.err.println("       java -jar bluelink.jar --prune <room-id> <days>");
+            System.err.println("       java -jar bluelink.jar --export-key <room-id> | --import-key <room-id> <base64>");
+            System.err.println("       java -jar bluelink.jar [--set-name <name>] [--set-color <#rrggbb>]");
+            System.exit(2);
+            return;
+        }
+
+        if (options.plain) System.setOut(PlainOutput.wrap(System.out));
+
+        if (options.exportKeyRoom != null || options.importKeyRoom != null) {
+            System.exit(manageKey(options));
+            return;
+        }
+
+        UserConfig config = options.guest ? UserConfig.guest() : UserConfig.loadOrCreate();
+        if (config.isMinimal()) options.minimal = true;
+        if (!options.minimal) printBanner();
+        if (config.isGuest()) System.out.printf("Joining as %s (guest — nothing is saved).%n", config.getUsername());
 
-        UserConfig config = UserConfig.loadOrCreate();
+        if (options.setName != null || options.setColor != null) {
+            try {
+                config.update(c -> {
+                    if (options.setName != null)  c.setUsername(options.setName);
+                    if (options.setColor != null) c.setColor(options.setColor);
+                });
+            } catch (IllegalArgumentException e) {
+                System.err.println(e.getMessage());
+                System.exit(2);
+            }
+            System.out.printf("Saved: %s (%s). Rooms you're in see the change next time you join.%n",
+                    config.getUsername(), config.getColor());
+            System.exit(0);
+        }
         FirebaseClient firebase = new FirebaseClient();
+        Scanner scanner = new Scanner(System.in);
+
+        if (options.listRooms) {
+            RoomList.run(config, firebase);
+            System.exit(0);
+        }
+
+        if (options.pruneRoom != null) {
+            System.exit(prune(options, config, firebase));
+            return;
+        }
+
+        if (options.pickRoom) {
+            options.roomId = RoomList.pick(config, firebase, scanner);
+            if (options.roomId == null) System.exit(0);
+        }
+
+        if (options.importFile != null) {
+            if (!firebase.checkRoomExists(options.roomId)) {
+                System.err.printf("Room %s does not exist.%n", options.roomId);
+                System.exit(1);
+            }
+            try {
+                if (!unlockRoom(options.roomId, firebase, config, scanner)) System.exit(1);
+                HistoryImport.run(Paths.get(options.importFile), options.roomId, config, firebase);
+            } catch (Exception e) {
+                System.err.println("Import failed: " + e.getMessage());
+                System.exit(1);
+            }
+            System.exit(0);
+        }
 
         String roomId;
-        Scanner scanner = new Scanner(System.in);
+        boolean created = false;
 
-        if (args.length > 0) {
-            roomId = args[0];
+        if (options.roomId != null) {
+            roomId = options.roomId;
             boolean exists = firebase.checkRoomExists(roomId);
+            if (!exists && options.readonly) {
+                System.err.printf("Room %s does not exist.%n", roomId);
+                System.exit(1);
+            }
             if (!exists) {
                 System.out.printf("Room %s does not exist. Create it? (y/N): ", roomId);
-                String response = scanner.nextLine().trim().toLowerCase();
+                String response = scanner.hasNextLine() ? scanner.nextLine().trim().toLowerCase() : "";
                 if (response.equals("y") || response.equals("yes")) {
-                    firebase.createRoomWithId(roomId, config.getUserId(), config.getUsername(), config.getColor());
+                    if (options.passphrase) choosePassphrase(firebase, scanner);
+                    firebase.createRoomWithId(roomId, config.getUserId(), config.getUsername(), config.getColor(),
+                            options.ttlSeconds);
+                    created = true;
                     System.out.printf("Room %s created.%n", roomId);
                 } else {
                     System.out.println("Exiting.");
                     System.exit(0);
                 }
             }
+        } else if (!options.newRoom && defaultRoomExists(config, firebase)) {
+            roomId = config.getDefaultRoom();
+        } else if (options.readonly) {
+            System.err.println("--readonly watches an existing room; pass its room ID.");
+            System.exit(2);
+            return;
         } else {
-            roomId = firebase.createRoom(config.getUserId(), config.getUsername(), config.getColor());
+            if (options.passphrase) choosePassphrase(firebase, scanner);
+            roomId = firebase.createRoom(config.getUserId(), config.getUsername(), config.getColor(),
+                    options.ttlSeconds);
+            created = true;
         }
 
-        System.out.printf("Connecting to room: %s%n", roomId);
-        System.out.println("Type a message and press Enter to send. Commands: /help, /clear, /exit");
-        System.out.println("─".repeat(60));
-
-        ChatSession session = new ChatSession(roomId, config, firebase, scanner);
+        if (!unlockRoom(roomId, firebase, config, scanner)) System.exit(1);
+        AtomicReference<ChatSession> current = new AtomicReference<>();
 
-        // Graceful shutdown on Ctrl+C
+        // Graceful shutdown on Ctrl+C; nothing to do once the session has stopped (/exit, /destroy)
         Runtime.getRuntime().addShutdownHook(new Thread(() -> {
+            ChatSession session = current.get();
+            if (session == null || !session.isRunning()) return;
             System.out.println("\nDisconnecting...");
             session.stop();
         }));
 
-        session.run();
+        // Each pass is one room; /join ends the session with the next room to enter
+        while (roomId != null) {
+            config.rememberRoom(roomId, created);
+            try {
+                config.save();
+            } catch (Exception e) {
+                // Non-fatal — the room just won't show up in `bluelink rooms`
+            }
+
+            System.out.printf("Connecting to room: %s%s%n", roomId, options.readonly ? " (spectating)" : "");
+            if (!options.minimal) {
+                System.out.println(config.getInputHint());
+                System.out.println("─".repeat(60));
+            }
+
+            ChatSession session = new ChatSession(roomId, config, firebase, scanner, options);
+            current.set(session);
+            String next = session.run();
+            if (next == null) break;
+
+            // The passphrase was for the first room; the next one asks for its own if it needs one
+            firebase.setPassphrase(null);
+            if (!unlockRoom(next, firebase, config, scanner)) System.exit(1);
+            roomId  = next;
+            created = false;
+        }
+        // The session has left; the Firebase SDK's threads would otherwise keep the JVM alive
+        System.exit(0);
+    }
+
+    /**
+     * Makes sure the client holds the right kind of key: loads the room's keyfile if there is
+     * one and asks for the passphrase of a room created with one, checking it against the
+     * room's key check. Returns false (having said why) if no right passphrase was given.
+     */
+    private static boolean unlockRoom(String roomId, FirebaseClient firebase, UserConfig config, Scanner scanner)
+            throws Exception {
+        // Guests never touch ~/.bluelink, so they only have the room-ID key
+        byte[] secret = config.isGuest() ? null : UserConfig.roomKey(roomId);
+        firebase.setRoomSecret(secret);
+        if (secret != null) System.out.printf("Using the keyfile for room %s.%n", roomId);
+
+        RoomMeta meta = firebase.getRoomMeta(roomId);
+        if (meta == null || !meta.isPassphraseProtected()) {
+            firebase.setPassphrase(null);
+            return true;
+        }
+        if (firebase.isUnlocked(roomId)) return true;   // just created it
+        for (int attempt = 1; attempt <= PASSPHRASE_ATTEMPTS; attempt++) {
+            String passphrase = readSecret("Room passphrase: ", scanner);
+            if (passphrase == null) break;
+            if (firebase.usePassphrase(roomId, passphrase)) return true;
+            System.out.println("Wrong passphrase.");
+        }
+        System.err.printf("Can't open room %s without its passphrase.%n", roomId);
+        return false;
+    }
+
+    /** Asks twice for the passphrase of a room about to be created with --passphrase. */
+    private static void choosePassphrase(FirebaseClient firebase, Scanner scanner) {
+        for (int attempt = 1; attempt <= PASSPHRASE_ATTEMPTS; attempt++) {
+            String passphrase = readSecret("Passphrase for the new room: ", scanner);
+            if (passphrase == null) break;
+            if (passphrase.isEmpty()) {
+                System.out.println("The passphrase can't be empty.");
+                continue;
+            }
+            if (passphrase.equals(readSecret("Repeat it: ", scanner))) {
+                firebase.setPassphrase(passphrase);
+                return;
+            }
+            System.out.println("The passphrases don't match.");
+        }
+        System.err.println("No passphrase chosen — not creating the room.");
+        System.exit(1);
+    }
+
+    /** Handles --prune for a room you created; returns the exit status. */
+    private static int prune(CliOptions options, UserConfig config, FirebaseClient firebase) {
+        String roomId = options.pruneRoom;
+        try {
+            RoomMeta meta = firebase.getRoomMeta(roomId);
+            if (meta == null) {
+                System.err.printf("Room %s does not exist.%n", roomId);
+                return 1;
+            }
+            if (!config.getUserId().equals(meta.getCreatorId())) {
+                System.err.println("Only the room creator can prune its messages.");
+                return 1;
+            }
+            long cutoff = System.currentTimeMillis() / 1000 - options.pruneDays * 86_400L;
+            int removed = firebase.pruneMessages(roomId, cutoff, PRUNE_KEEP);
+            System.out.printf("Deleted %d message%s older than %d day%s from room %s (the newest %d are always kept).%n",
+                    removed, removed == 1 ? "" : "s", options.pruneDays, options.pruneDays == 1 ? "" : "s", roomId, PRUNE_KEEP);
+            return 0;
+        } catch (Exception e) {
+            System.err.println("Prune failed: " + e.getMessage());
+            return 1;
+        }
+    }
+
+    /** Handles --export-key / --import-key; returns the exit status. */
+    private static int manageKey(CliOptions options) {
+        try {
+            if (options.importKeyRoom != null) {
+                byte[] key = Base64.getDecoder().decode(options.importKey.trim());
+                UserConfig.importRoomKey(options.importKeyRoom, key);
+                System.out.printf("Imported the key for room %s.%n", options.importKeyRoom);
+                return 0;
+            }
+            boolean existed = UserConfig.roomKey(options.exportKeyRoom) != null;
+            byte[] key = UserConfig.createRoomKey(options.exportKeyRoom);
+            if (!existed) {
+                System.err.printf("Generated a new key for room %s. Everyone in the room must import it "
+                        + "(bluelink --import-key %s <key>) to keep reading it.%n", options.exportKeyRoom, options.exportKeyRoom);
+            }
+            System.out.println(Base64.getEncoder().encodeToString(key));
+            return 0;
+        } catch (Exception e) {
+            System.err.println("Key " + (options.importKeyRoom != null ? "import" : "export") + " failed: " + e.getMessage());
+            return 1;
+        }
+    }
+
+    /** Reads a line without echo when there's a console (falls back to plain input when piped). */
+    static String readSecret(String prompt, Scanner scanner) {
+        Console console = System.console();
+        if (console != null) {
+            char[] secret = console.readPassword(prompt);
+            return secret == null ? null : new String(secret);
+        }
+        System.out.print(prompt);
+        return scanner.hasNextLine() ? scanner.nextLine() : null;
+    }
+
+    /** True if a defaultRoom is configured and still there; says so when it has vanished. */
+    private static boolean defaultRoomExists(UserConfig config, FirebaseClient firebase) throws Exception {
+        String room = config.getDefaultRoom();
+        if (room == null || room.isBlank()) return false;
+        if (firebase.checkRoomExists(room)) return true;
+        System.out.printf("Default room %s no longer exists — creating a new room.%n", room);
+        return false;
     }
 
     private static void printBanner() {
@@ -0,0 +1,46 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * Finds @name mentions in message text.
+ *
+ * A mention starts with @ that isn't glued to a preceding word (so mail@example.com
+ * doesn't count) and ends at the last letter, digit or underscore, so "@bob," and
+ * "(@bob)" mention bob while "@bobby" does not. Names with spaces are mentioned
+ * without them: Bob Smith is @BobSmith. Matching is case-insensitive.
+ */
+final class Mentions {
+
+    static final Pattern MENTION = Pattern.compile(
+            "(?<![\\p{L}\\p{N}_@])@([\\p{L}\\p{N}_](?:[\\p{L}\\p{N}_.-]*[\\p{L}\\p{N}_])?)");
+
+    private Mentions() {}
+
+    /** The names mentioned in text, without the @, in order of appearance. */
+    static List<String> extract(String text) {
+        List<String> names = new ArrayList<>();
+        if (text == null) return names;
+        Matcher m = MENTION.matcher(text);
+        while (m.find()) names.add(m.group(1));
+        return names;
+    }
+
+    /** True if text mentions username (ignoring case and any spaces in the name). */
+    static boolean mentions(String text, String username) {
+        if (username == null || username.isBlank()) return false;
+        String handle = handle(username);
+        for (String name : extract(text)) {
+            if (name.equalsIgnoreCase(handle)) return true;
+        }
+        return false;
+    }
+
+    /** How username is written after the @: the name with whitespace removed. */
+    static String handle(String username) {
+        return username.replaceAll("\\s+", "");
+    }
+}
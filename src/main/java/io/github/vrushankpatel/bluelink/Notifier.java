@@ -0,0 +1,52 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.io.IOException;
+import java.util.List;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * Desktop notifications through the platform's own tool: notify-send on Linux,
+ * osascript on macOS and a PowerShell balloon tip on Windows.
+ *
+ * Title and body are passed as arguments or environment variables, never spliced
+ * into a script, so message text can't inject commands.
+ */
+final class Notifier {
+
+    private static final int  MAX_BODY     = 200;
+    private static final long WAIT_SECONDS = 10;
+
+    private static final String OS = System.getProperty("os.name", "").toLowerCase();
+
+    private Notifier() {}
+
+    /** Shows a notification and waits briefly for the tool; throws if it's missing or fails. */
+    static void send(String title, String body) throws IOException, InterruptedException {
+        if (body.length() > MAX_BODY) body = body.substring(0, MAX_BODY) + "…";
+        ProcessBuilder pb = new ProcessBuilder(command(title, body));
+        pb.environment().put("BLUELINK_TITLE", title);
+        pb.environment().put("BLUELINK_BODY", body);
+        pb.redirectErrorStream(true).redirectOutput(ProcessBuilder.Redirect.DISCARD);
+        Process p = pb.start();
+        if (!p.waitFor(WAIT_SECONDS, TimeUnit.SECONDS)) p.destroy();
+    }
+
+    private static List<String> command(String title, String body) {
+        if (OS.contains("mac")) {
+            return List.of("osascript",
+                    "-e", "on run argv",
+                    "-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+                    "-e", "end run",
+                    title, body);
+        }
+        if (OS.contains("win")) {
+            return List.of("powershell", "-NoProfile", "-NonInteractive", "-Command",
+                    "Add-Type -AssemblyName System.Windows.Forms; "
+                    + "$n = New-Object System.Windows.Forms.NotifyIcon; "
+                    + "$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; "
+                    + "$n.ShowBalloonTip(5000, $env:BLUELINK_TITLE, $env:BLUELINK_BODY, 'None'); "
+                    + "Start-Sleep -Seconds 6; $n.Dispose()");
+        }
+        return List.of("notify-send", "--app-name=BlueLink", "--", title, body);
+    }
+}
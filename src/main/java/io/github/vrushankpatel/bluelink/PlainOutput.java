@@ -0,0 +1,54 @@
+package io.github.vrushankpatel.bluelink;
+
+import java.io.FilterOutputStream;
+import java.io.IOException;
+import java.io.OutputStream;
+import java.io.PrintStream;
+import java.nio.charset.StandardCharsets;
+
+/**
+ * --plain: an output stream that drops ANSI escape sequences (colors, bold, screen
+ * clears) and the terminal bell, so output can be piped, logged or read by a screen
+ * reader as plain text.
+ *
+ * Escape sequences are pure ASCII, so filtering byte by byte leaves UTF-8 intact.
+ */
+final class PlainOutput extends FilterOutputStream {
+
+    private static final int ESC  = 0x1b;
+    private static final int BELL = 0x07;
+
+    private enum State { TEXT, ESCAPE, CSI }
+
+    private State state = State.TEXT;
+
+    private PlainOutput(OutputStream out) {
+        super(out);
+    }
+
+    /** Wraps stream in a PrintStream that strips escapes, flushing on every line like System.out. */
+    static PrintStream wrap(PrintStream stream) {
+        return new PrintStream(new PlainOutput(stream), true, StandardCharsets.UTF_8);
+    }
+
+    @Override
+    public synchronized void write(int b) throws IOException {
+        switch (state) {
+            case TEXT -> {
+                if (b == ESC) state = State.ESCAPE;
+                else if (b != BELL) out.write(b);
+            }
+            // ESC [ starts a control sequence; any other ESC x is a two-byte sequence
+            case ESCAPE -> state = b == '[' ? State.CSI : State.TEXT;
+            // Parameters and intermediates run until a final byte in @..~
+            case CSI -> {
+                if (b >= 0x40 && b <= 0x7e) state = State.TEXT;
+            }
+        }
+    }
+
+    @Override
+    public synchronized void write(byte[] b, int off, int len) throws IOException {
+        for (int i = off; i < off + len; i++) write(b[i]);
+    }
+}
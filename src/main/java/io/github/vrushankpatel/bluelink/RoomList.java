@@ -0,0 +1,117 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.config.UserConfig;
+import io.github.vrushankpatel.bluelink.firebase.FirebaseClient;
+import io.github.vrushankpatel.bluelink.firebase.RoomMeta;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Scanner;
+import java.util.concurrent.ExecutorService;
+import java.util.concurrent.Executors;
+import java.util.concurrent.Future;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.TimeoutException;
+
+/**
+ * `bluelink rooms` and `--list-rooms`: lists the rooms this config has created or
+ * joined, with what can still be learned about each. There's no server-side directory — the lists
+ * live only in the local config.
+ */
+final class RoomList {
+
+    // Rooms are looked up together; any still unanswered after this are listed without a status
+    private static final long LOOKUP_SECONDS = 5;
+
+    private RoomList() {}
+
+    static void run(UserConfig config, FirebaseClient firebase) {
+        print(config, firebase);
+    }
+
+    /**
+     * `bluelink --list-rooms`: lists the rooms, then asks which to join.
+     * Returns the chosen room ID, or null if there are none or the user just pressed Enter.
+     */
+    static String pick(UserConfig config, FirebaseClient firebase, Scanner scanner) {
+        if (!print(config, firebase)) return null;
+        List<String> recent = config.getRecentRooms();
+        while (true) {
+            System.out.print("Join which room? (1-" + recent.size() + ", Enter to quit): ");
+            if (!scanner.hasNextLine()) return null;
+            String answer = scanner.nextLine().trim();
+            if (answer.isEmpty()) return null;
+            try {
+                int n = Integer.parseInt(answer);
+                if (n >= 1 && n <= recent.size()) return recent.get(n - 1);
+            } catch (NumberFormatException ignored) {
+                // Ask again
+            }
+            System.out.println("Enter a number from the list.");
+        }
+    }
+
+    /** Prints the numbered room list; returns false if there's nothing to list. */
+    private static boolean print(UserConfig config, FirebaseClient firebase) {
+        List<String> recent = config.getRecentRooms();
+        if (recent.isEmpty()) {
+            System.out.println("No rooms yet — start one with: bluelink");
+            return false;
+        }
+        System.out.println("Your rooms (most recent first):");
+        List<String> status = describeAll(recent, firebase);
+        for (int i = 0; i < recent.size(); i++) {
+            String roomId = recent.get(i);
+            String role = config.getCreatedRooms().contains(roomId) ? "created" : "joined";
+            System.out.printf("  %2d. %-24s %-8s %s%n", i + 1, roomId, role, status.get(i));
+        }
+        return true;
+    }
+
+    /** Describes every room in parallel, waiting at most LOOKUP_SECONDS for all of them together. */
+    private static List<String> describeAll(List<String> roomIds, FirebaseClient firebase) {
+        ExecutorService pool = Executors.newFixedThreadPool(Math.min(roomIds.size(), 8), r -> {
+            Thread t = new Thread(r, "room-list");
+            t.setDaemon(true);
+            return t;
+        });
+        List<Future<String>> lookups = new ArrayList<>();
+        for (String roomId : roomIds) lookups.add(pool.submit(() -> describe(roomId, firebase)));
+        pool.shutdown();
+
+        long deadline = System.nanoTime() + TimeUnit.SECONDS.toNanos(LOOKUP_SECONDS);
+        List<String> status = new ArrayList<>();
+        for (Future<String> lookup : lookups) {
+            try {
+                status.add(lookup.get(Math.max(0, deadline - System.nanoTime()), TimeUnit.NANOSECONDS));
+            } catch (TimeoutException e) {
+                status.add("(no answer)");
+            } catch (InterruptedException e) {
+                Thread.currentThread().interrupt();
+                status.add("(no answer)");
+            } catch (Exception e) {
+                status.add("(unreachable: " + e.getMessage() + ")");
+            }
+        }
+        pool.shutdownNow();
+        return status;
+    }
+
+    /** One-line status of a room, from its meta and participants. */
+    private static String describe(String roomId, FirebaseClient firebase) {
+        try {
+            // Meta answers for every room made since it existed; only older ones need the full existence check
+            RoomMeta meta = firebase.getRoomMeta(roomId);
+            if (meta == null && !firebase.checkRoomExists(roomId)) return "(gone)";
+            int people = firebase.getParticipants(roomId).size();
+            String info = people + (people == 1 ? " participant" : " participants");
+            if (meta != null && meta.isBroadcast()) info += ", broadcast";
+            if (meta != null && meta.getExpiresAt() > 0) {
+                info += ", expires " + new java.util.Date(meta.getExpiresAt() * 1000);
+            }
+            return info;
+        } catch (Exception e) {
+            return "(unreachable: " + e.getMessage() + ")";
+        }
+    }
+}
@@ -5,7 +5,14 @@ import com.google.gson.GsonBuilder;
 
 import java.io.*;
 import java.nio.file.*;
+import java.nio.file.attribute.PosixFilePermissions;
+import java.security.SecureRandom;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
 import java.util.UUID;
+import java.util.function.Consumer;
 
 /**
  * Persists user identity (id, username, color) in ~/.bluelink/config.json.
@@ -14,12 +21,117 @@ public class UserConfig {
 
     private static final String CONFIG_DIR  = ".bluelink";
     private static final String CONFIG_FILE = "config.json";
+    private static final String KEYS_DIR    = "keys";
+    private static final int    KEY_BYTES   = 32;
+    private static final int    MAX_NAME    = 32;
     private static final Gson   GSON        = new GsonBuilder().setPrettyPrinting().create();
 
     private String userId;
     private String username;
     private String color;
 
+    // Link previews fetch third-party pages, so they're off unless enabled here
+    // and opted into per room with /preview on
+    private boolean      linkPreviews = false;
+    private List<String> previewRooms = new ArrayList<>();
+
+    // Desktop notification for each new message from someone else (toggle with /notify)
+    private boolean notifications = false;
+
+    // Room ID -> user IDs whose messages never notify, ring the bell or stand out there (/mutenotify)
+    private Map<String, List<String>> notifyMuted = new HashMap<>();
+
+    // Rejoining within this window doesn't post another "joined the room" message
+    private long joinDebounceSeconds = 60;
+
+    // How often lastActive is refreshed while connected, even if you never type
+    private long heartbeatSeconds = 30;
+
+    // Presence writes (from sends and heartbeats) are coalesced to at most one per this many seconds
+    private long activityIntervalSeconds = 15;
+
+    // Participants active within this window count as online
+    private long presenceTimeoutSeconds = 90;
+
+    // Exit after the connection has been down this long; 0 keeps retrying forever
+    private long reconnectWindowSeconds = 300;
+
+    // Same as --minimal: no banner, rules or boxes around the chat
+    private boolean minimal = false;
+
+    // Hint printed above the input on connect; null keeps the built-in text
+    private String inputHint;
+
+    public static final String TS_ABSOLUTE = "absolute";
+    public static final String TS_RELATIVE = "relative";
+    public static final String TS_HIDDEN   = "hidden";
+
+    // absolute | relative | hidden; configs without it fall back to showTimestamps
+    private String  timestampMode;
+    private boolean showTimestamps = true;
+
+    public static final String FEEDBACK_ALL      = "all";
+    public static final String FEEDBACK_FAILURES = "failures";
+    public static final String FEEDBACK_NONE     = "none";
+
+    // Extra send feedback: all = bell on failure + "Sent." on success, failures = bell on failure only.
+    // Failures are always reported in text either way.
+    private String feedbackMode = FEEDBACK_NONE;
+
+    public static final String DUPLICATE_WARN  = "warn";
+    public static final String DUPLICATE_BLOCK = "block";
+
+    // Joining a room this config is already connected to: warn and share the participant, or refuse
+    private String duplicateSession = DUPLICATE_WARN;
+
+    // Off: stop broadcasting lastActive after joining (others will see you as away)
+    private boolean sharePresence = true;
+
+    // Off: unrecognized /commands are sent as ordinary messages instead of rejected
+    private boolean strictCommands = true;
+
+    // Upper bound on messages downloaded and decrypted at once
+    private int maxHistory = 5000;
+
+    // Show joins and departures in the chat; off keeps them for /activity only
+    private boolean eventsInline = true;
+
+    // Messages loaded on join and per /more page
+    private int historyPageSize = 50;
+
+    // Show each message as a preview and require a second Enter to send it
+    private boolean confirmSend = false;
+
+    // Words that highlight a message and ring the terminal bell, matched case-insensitively
+    private List<String> alertKeywords = new ArrayList<>();
+
+    // Appended to your "left the room" notice; /exit <message> overrides it once
+    private String leaveMessage;
+
+    // A sender posting this many messages within floodWindowSeconds is collapsed; 0 disables
+    private int  floodThreshold     = 6;
+    private long floodWindowSeconds = 10;
+
+    // Sent as an ordinary (encrypted) message right after joining, e.g. "monitor online"
+    private String joinMessage;
+
+    // "#rrggbb" to draw your own name in a fixed color on this terminal only; unset = plain
+    private String selfColor;
+
+    // "#rrggbb" accent for rules and banner frames in every room, overriding rooms' own themes
+    private String theme;
+
+    // Joined by a bare `bluelink` instead of creating a room; --new overrides it
+    private String defaultRoom;
+
+    // Rooms you created / joined, most recent first, for `bluelink rooms`
+    private static final int ROOM_HISTORY = 20;
+    private List<String> createdRooms = new ArrayList<>();
+    private List<String> recentRooms  = new ArrayList<>();
+
+    // Guest identities live only in memory; save() leaves the disk untouched
+    private transient boolean ephemeral;
+
     // Gson needs a no-arg constructor
     public UserConfig() {}
 
@@ -44,14 +156,19 @@ public class UserConfig {
         return createNew(configPath);
     }
 
+    /** A throwaway identity ("guest-1234") that is never written to disk. */
+    public static UserConfig guest() {
+        String userId = "user_" + UUID.randomUUID().toString().replace("-", "").substring(0, 8);
+        String name   = "guest-" + (1000 + new java.util.Random().nextInt(9000));
+        UserConfig cfg = new UserConfig(userId, name, randomHexColor());
+        cfg.ephemeral = true;
+        return cfg;
+    }
+
     private static UserConfig createNew(Path configPath) throws IOException {
         System.out.print("Enter your name: ");
         BufferedReader br = new BufferedReader(new InputStreamReader(System.in));
-        String name = br.readLine();
-        if (name == null || name.isBlank()) {
-            throw new IllegalArgumentException("Name cannot be empty.");
-        }
-        name = name.trim();
+        String name = validName(br.readLine());
 
         String userId = "user_" + UUID.randomUUID().toString().replace("-", "").substring(0, 8);
         String color  = randomHexColor();
@@ -63,10 +180,92 @@ public class UserConfig {
         return cfg;
     }
 
+    /** Writes the current settings back to ~/.bluelink/config.json. */
+    public void save() throws IOException {
+        if (ephemeral) return;
+        try (Writer w = Files.newBufferedWriter(configPath())) {
+            GSON.toJson(this, w);
+        }
+    }
+
+    /**
+     * Applies a change to these settings and to the file on disk. The file is re-read first,
+     * so edits made elsewhere since startup survive, and replaced atomically. Guests only
+     * change in memory.
+     */
+    public void update(Consumer<UserConfig> mutate) throws IOException {
+        mutate.accept(this);
+        if (ephemeral) return;
+        Path configPath = configPath();
+        UserConfig onDisk;
+        try (Reader r = Files.newBufferedReader(configPath)) {
+            onDisk = GSON.fromJson(r, UserConfig.class);
+        }
+        mutate.accept(onDisk);
+        Path tmp = configPath.resolveSibling(CONFIG_FILE + ".tmp");
+        try (Writer w = Files.newBufferedWriter(tmp)) {
+            GSON.toJson(onDisk, w);
+        }
+        Files.move(tmp, configPath, StandardCopyOption.REPLACE_EXISTING, StandardCopyOption.ATOMIC_MOVE);
+    }
+
+    // ── room keyfiles ─────────────────────────────────────────────────────────
+
+    /** The secret in {@code ~/.bluelink/keys/<roomId>.key}, or null if the room has none (room-ID key). */
+    public static byte[] roomKey(String roomId) throws IOException {
+        Path path = keyPath(roomId);
+        if (!Files.exists(path)) return null;
+        byte[] key = Files.readAllBytes(path);
+        if (key.length != KEY_BYTES) throw new IOException("Keyfile " + path + " is not a " + KEY_BYTES + "-byte key");
+        return key;
+    }
+
+    /** Returns the room's keyfile secret, generating a random one first if there is none. */
+    public static byte[] createRoomKey(String roomId) throws IOException {
+        byte[] key = roomKey(roomId);
+        if (key != null) return key;
+        key = new byte[KEY_BYTES];
+        new SecureRandom().nextBytes(key);
+        writeRoomKey(roomId, key);
+        return key;
+    }
+
+    /** Stores a key shared by someone else, replacing any existing keyfile for the room. */
+    public static void importRoomKey(String roomId, byte[] key) throws IOException {
+        if (key.length != KEY_BYTES) throw new IllegalArgumentException("A room key is " + KEY_BYTES + " bytes, got " + key.length);
+        writeRoomKey(roomId, key);
+    }
+
+    private static void writeRoomKey(String roomId, byte[] key) throws IOException {
+        Path path = keyPath(roomId);
+        Files.createDirectories(path.getParent());
+        Path tmp = path.resolveSibling(path.getFileName() + ".tmp");
+        Files.write(tmp, key);
+        try {
+            Files.setPosixFilePermissions(tmp, PosixFilePermissions.fromString("rw-------"));
+        } catch (UnsupportedOperationException ignored) {
+            // Not a POSIX filesystem
+        }
+        Files.move(tmp, path, StandardCopyOption.REPLACE_EXISTING, StandardCopyOption.ATOMIC_MOVE);
+    }
+
+    private static Path keyPath(String roomId) {
+        // Room IDs end up in a file name, so keep them to a safe alphabet
+        if (roomId == null || !roomId.matches("[A-Za-z0-9_-]+")) {
+            throw new IllegalArgumentException("Invalid room ID: " + roomId);
+        }
+        return configDir().resolve(KEYS_DIR).resolve(roomId + ".key");
+    }
+
     // ── helpers ───────────────────────────────────────────────────────────────
 
+    /** The ~/.bluelink directory holding the config and other local state. */
+    public static Path configDir() {
+        return Paths.get(System.getProperty("user.home"), CONFIG_DIR);
+    }
+
     private static Path configPath() {
-        return Paths.get(System.getProperty("user.home"), CONFIG_DIR, CONFIG_FILE);
+        return configDir().resolve(CONFIG_FILE);
     }
 
     /** Returns a random bright hex color suitable for terminal display. */
@@ -82,4 +281,146 @@ public class UserConfig {
     public String getUserId()   { return userId; }
     public String getUsername() { return username; }
     public String getColor()    { return color; }
+    public boolean isGuest()    { return ephemeral; }
+
+    public boolean isLinkPreviews()        { return linkPreviews; }
+    public boolean isSharePresence()       { return sharePresence; }
+    public boolean isStrictCommands()      { return strictCommands; }
+    public boolean isConfirmSend()         { return confirmSend; }
+    public boolean isEventsInline()        { return eventsInline; }
+    public boolean isMinimal()             { return minimal; }
+    public boolean isNotifications()       { return notifications; }
+    public long    getJoinDebounceSeconds() { return joinDebounceSeconds; }
+    public long    getHeartbeatSeconds()    { return Math.max(5, heartbeatSeconds); }
+    public long    getPresenceTimeoutSeconds() { return presenceTimeoutSeconds; }
+    public long    getActivityIntervalSeconds() { return Math.max(1, activityIntervalSeconds); }
+    public long    getReconnectWindowSeconds() { return Math.max(0, reconnectWindowSeconds); }
+    public int     getMaxHistory()          { return Math.max(1, maxHistory); }
+    public int     getHistoryPageSize()     { return Math.max(1, Math.min(historyPageSize, getMaxHistory())); }
+    public int     getFloodThreshold()      { return floodThreshold; }
+    public long    getFloodWindowSeconds()  { return floodWindowSeconds; }
+
+    public String getTimestampMode() {
+        if (TS_ABSOLUTE.equals(timestampMode) || TS_RELATIVE.equals(timestampMode) || TS_HIDDEN.equals(timestampMode)) {
+            return timestampMode;
+        }
+        return showTimestamps ? TS_ABSOLUTE : TS_HIDDEN;
+    }
+
+    public String getFeedbackMode() {
+        return FEEDBACK_ALL.equals(feedbackMode) || FEEDBACK_FAILURES.equals(feedbackMode) ? feedbackMode : FEEDBACK_NONE;
+    }
+
+    public String getDuplicateSession() {
+        return DUPLICATE_BLOCK.equals(duplicateSession) ? DUPLICATE_BLOCK : DUPLICATE_WARN;
+    }
+
+    public List<String> getAlertKeywords() {
+        return alertKeywords == null ? List.of() : alertKeywords;
+    }
+
+    public String getLeaveMessage() { return leaveMessage; }
+    public String getJoinMessage()  { return joinMessage; }
+    public String getDefaultRoom()  { return defaultRoom; }
+    public String getSelfColor()    { return selfColor; }
+    public String getTheme()        { return theme; }
+
+    public String getInputHint() {
+        return inputHint == null || inputHint.isBlank()
+                ? "Type a message and press Enter to send. Commands: /help, /clear, /exit"
+                : inputHint;
+    }
+
+    public List<String> getCreatedRooms() {
+        return createdRooms == null ? List.of() : createdRooms;
+    }
+
+    public List<String> getRecentRooms() {
+        return recentRooms == null ? List.of() : recentRooms;
+    }
+
+    public boolean isPreviewEnabled(String roomId) {
+        return linkPreviews && previewRooms.contains(roomId);
+    }
+
+    /** True if userId's messages in roomId are kept from notifying you. */
+    public boolean isNotifyMuted(String roomId, String userId) {
+        return getNotifyMuted(roomId).contains(userId);
+    }
+
+    /** User IDs muted with /mutenotify in roomId. */
+    public List<String> getNotifyMuted(String roomId) {
+        List<String> muted = notifyMuted == null ? null : notifyMuted.get(roomId);
+        return muted == null ? List.of() : muted;
+    }
+
+    // ── setters ───────────────────────────────────────────────────────────────
+
+    /** Sets the display name; it must be non-blank and under 32 characters. */
+    public void setUsername(String username) {
+        this.username = validName(username);
+    }
+
+    /** Sets the color others see your name in, as "#rrggbb". */
+    public void setColor(String color) {
+        this.color = validColor(color);
+    }
+
+    /** Returns the trimmed name, or throws IllegalArgumentException explaining what's wrong with it. */
+    public static String validName(String name) {
+        String trimmed = name == null ? "" : name.trim();
+        if (trimmed.isEmpty()) throw new IllegalArgumentException("Name cannot be empty.");
+        if (trimmed.length() >= MAX_NAME) throw new IllegalArgumentException("Name must be under " + MAX_NAME + " characters.");
+        if (trimmed.chars().anyMatch(Character::isISOControl)) throw new IllegalArgumentException("Name cannot contain control characters.");
+        return trimmed;
+    }
+
+    /** Returns the color in upper case, or throws IllegalArgumentException if it isn't "#rrggbb". */
+    public static String validColor(String color) {
+        if (color == null || !color.trim().matches("#[0-9A-Fa-f]{6}")) {
+            throw new IllegalArgumentException("Color must look like #RRGGBB.");
+        }
+        return color.trim().toUpperCase();
+    }
+
+    public void setNotifications(boolean notifications) {
+        this.notifications = notifications;
+    }
+
+    /** Sets how often lastActive is refreshed; anything under 5 seconds counts as 5. */
+    public void setHeartbeatSeconds(long heartbeatSeconds) {
+        this.heartbeatSeconds = heartbeatSeconds;
+    }
+
+    public void setTimestampMode(String mode) {
+        this.timestampMode  = mode;
+        this.showTimestamps = !TS_HIDDEN.equals(mode);
+    }
+
+    /** Moves roomId to the front of the recent (and, if created, created) room lists. */
+    public void rememberRoom(String roomId, boolean created) {
+        if (recentRooms == null)  recentRooms  = new ArrayList<>();
+        if (createdRooms == null) createdRooms = new ArrayList<>();
+        pushFront(recentRooms, roomId);
+        if (created) pushFront(createdRooms, roomId);
+    }
+
+    private static void pushFront(List<String> rooms, String roomId) {
+        rooms.remove(roomId);
+        rooms.add(0, roomId);
+        while (rooms.size() > ROOM_HISTORY) rooms.remove(rooms.size() - 1);
+    }
+
+    public void setPreviewEnabled(String roomId, boolean enabled) {
+        previewRooms.remove(roomId);
+        if (enabled) previewRooms.add(roomId);
+    }
+
+    public void setNotifyMuted(String roomId, String userId, boolean muted) {
+        if (notifyMuted == null) notifyMuted = new HashMap<>();
+        List<String> users = notifyMuted.computeIfAbsent(roomId, r -> new ArrayList<>());
+        users.remove(userId);
+        if (muted) users.add(userId);
+        if (users.isEmpty()) notifyMuted.remove(roomId);
+    }
 }
@@ -1,20 +1,33 @@
 package io.github.vrushankpatel.bluelink.firebase;
 
 import javax.crypto.Cipher;
-import javax.crypto.SecretKey;
+import javax.crypto.Mac;
+import javax.crypto.SecretKeyFactory;
+import javax.crypto.spec.PBEKeySpec;
 import javax.crypto.spec.GCMParameterSpec;
 import javax.crypto.spec.SecretKeySpec;
 import java.nio.ByteBuffer;
 import java.security.MessageDigest;
+import java.nio.charset.StandardCharsets;
 import java.security.SecureRandom;
 import java.util.Base64;
 
 /**
  * AES-256-GCM encryption/decryption.
  *
- * Key derivation : SHA-256 of the room ID (same scheme as the Go version).
+ * Key derivation : SHA-256 of the room ID (same scheme as the Go version). A room
+ *                  created with a passphrase uses PBKDF2-HMAC-SHA256 of the passphrase
+ *                  with a random per-room salt kept in the room's meta, so guesses
+ *                  are slow to try offline; rooms from before salts used SHA-256 of
+ *                  "roomId:passphrase". The passphrase itself is never written
+ *                  anywhere. A room with a local keyfile uses its 32 random bytes
+ *                  instead of the room ID.
  * Nonce          : 12 random bytes from SecureRandom prepended to the ciphertext
  *                  (improvement over the Go version's deterministic nonce).
+ * AAD            : "roomId:senderId", so a ciphertext can't be replayed into another
+ *                  room or re-attributed to another sender. Bodies sealed before
+ *                  AAD binding open with decryptLegacy, which can't tell where they
+ *                  came from; callers only use it for messages not marked bound.
  *
  * Wire format (Base64): [ 12-byte nonce | ciphertext+tag ]
  */
@@ -23,18 +36,20 @@ final class Crypto {
     private static final String ALGORITHM  = "AES/GCM/NoPadding";
     private static final int    NONCE_LEN  = 12;   // 96-bit nonce — GCM standard
     private static final int    TAG_BITS   = 128;  // 128-bit authentication tag
+    private static final int    SALT_LEN   = 16;
+    private static final int    KDF_ROUNDS = 600_000;   // OWASP's figure for PBKDF2-HMAC-SHA256
 
     private static final SecureRandom RANDOM = new SecureRandom();
 
     private Crypto() {}
 
-    static String encrypt(String plaintext, String roomId) throws Exception {
-        SecretKey key   = deriveKey(roomId);
-        byte[]    nonce = new byte[NONCE_LEN];
+    static String encrypt(String plaintext, byte[] key, String roomId, String senderId) throws Exception {
+        byte[] nonce = new byte[NONCE_LEN];
         RANDOM.nextBytes(nonce);
 
         Cipher cipher = Cipher.getInstance(ALGORITHM);
-        cipher.init(Cipher.ENCRYPT_MODE, key, new GCMParameterSpec(TAG_BITS, nonce));
+        cipher.init(Cipher.ENCRYPT_MODE, new SecretKeySpec(key, "AES"), new GCMParameterSpec(TAG_BITS, nonce));
+        cipher.updateAAD(associatedData(roomId, senderId));
         byte[] ciphertext = cipher.doFinal(plaintext.getBytes("UTF-8"));
 
         // Prepend nonce to ciphertext
@@ -44,7 +59,80 @@ final class Crypto {
         return Base64.getEncoder().encodeToString(buf.array());
     }
 
-    static String decrypt(String encoded, String roomId) throws Exception {
+    /** Opens a body sealed for roomId and senderId; throws AEADBadTagException for anything else. */
+    static String decrypt(String encoded, byte[] key, String roomId, String senderId) throws Exception {
+        return open(key, encoded, associatedData(roomId, senderId));
+    }
+
+    /**
+     * Opens a body sealed without associated data, as clients did before AAD binding. Nothing
+     * ties it to a room or sender, so the result must be shown as unverified.
+     */
+    static String decryptLegacy(String encoded, byte[] key) throws Exception {
+        return open(key, encoded, null);
+    }
+
+    /**
+     * The legacy key: derived from the room ID, so anyone with the ID can read. With a
+     * passphrase this is only for rooms created before salts (a single unsalted hash).
+     */
+    static byte[] deriveKey(String roomId, String passphrase) throws Exception {
+        String seed = passphrase == null || passphrase.isEmpty() ? roomId : roomId + ":" + passphrase;
+        return MessageDigest.getInstance("SHA-256").digest(seed.getBytes(StandardCharsets.UTF_8));
+    }
+
+    /** The key for a room with a keyfile: the secret itself, or (rooms from before salts) SHA-256 of "secret:passphrase". */
+    static byte[] deriveKey(byte[] secret, String passphrase) throws Exception {
+        if (passphrase == null || passphrase.isEmpty()) return secret.clone();
+        MessageDigest sha = MessageDigest.getInstance("SHA-256");
+        sha.update(secret);
+        sha.update((":" + passphrase).getBytes(StandardCharsets.UTF_8));
+        return sha.digest();
+    }
+
+    /** A fresh random salt for a new passphrase-protected room. */
+    static byte[] newSalt() {
+        byte[] salt = new byte[SALT_LEN];
+        RANDOM.nextBytes(salt);
+        return salt;
+    }
+
+    /** The key for a salted passphrase room: PBKDF2-HMAC-SHA256, slow on purpose. */
+    static byte[] stretch(String passphrase, byte[] salt) throws Exception {
+        PBEKeySpec spec = new PBEKeySpec(passphrase.toCharArray(), salt, KDF_ROUNDS, 256);
+        try {
+            return SecretKeyFactory.getInstance("PBKDF2WithHmacSHA256").generateSecret(spec).getEncoded();
+        } finally {
+            spec.clearPassword();
+        }
+    }
+
+    /** The key for a room with both a keyfile and a salted passphrase: SHA-256 of secret + stretched key. */
+    static byte[] combine(byte[] secret, byte[] stretched) throws Exception {
+        MessageDigest sha = MessageDigest.getInstance("SHA-256");
+        sha.update(secret);
+        sha.update(stretched);
+        return sha.digest();
+    }
+
+    /**
+     * A check value stored in the room's meta, so a wrong passphrase can be told apart from
+     * a right one on joining. It's a MAC of a fixed string under the stretched key, so it
+     * reveals nothing about the key and costs a guesser the same PBKDF2 rounds.
+     */
+    static String keyCheck(byte[] stretched) throws Exception {
+        Mac mac = Mac.getInstance("HmacSHA256");
+        mac.init(new SecretKeySpec(stretched, "HmacSHA256"));
+        return Base64.getEncoder().encodeToString(mac.doFinal("bluelink key check".getBytes(StandardCharsets.UTF_8)));
+    }
+
+    /** True if stretched matches the stored check value (compared in constant time). */
+    static boolean matchesKeyCheck(byte[] stretched, String check) throws Exception {
+        return MessageDigest.isEqual(keyCheck(stretched).getBytes(StandardCharsets.UTF_8),
+                check.getBytes(StandardCharsets.UTF_8));
+    }
+
+    private static String open(byte[] key, String encoded, byte[] aad) throws Exception {
         byte[]     raw    = Base64.getDecoder().decode(encoded);
         ByteBuffer buf    = ByteBuffer.wrap(raw);
 
@@ -53,16 +141,14 @@ final class Crypto {
         buf.get(nonce);
         buf.get(ciphertext);
 
-        SecretKey key    = deriveKey(roomId);
-        Cipher    cipher = Cipher.getInstance(ALGORITHM);
-        cipher.init(Cipher.DECRYPT_MODE, key, new GCMParameterSpec(TAG_BITS, nonce));
+        Cipher cipher = Cipher.getInstance(ALGORITHM);
+        cipher.init(Cipher.DECRYPT_MODE, new SecretKeySpec(key, "AES"), new GCMParameterSpec(TAG_BITS, nonce));
+        if (aad != null) cipher.updateAAD(aad);
         byte[] plaintext = cipher.doFinal(ciphertext);
         return new String(plaintext, "UTF-8");
     }
 
-    private static SecretKey deriveKey(String roomId) throws Exception {
-        MessageDigest sha = MessageDigest.getInstance("SHA-256");
-        byte[]        raw = sha.digest(roomId.getBytes("UTF-8"));
-        return new SecretKeySpec(raw, "AES");
+    private static byte[] associatedData(String roomId, String senderId) throws Exception {
+        return (roomId + ":" + senderId).getBytes("UTF-8");
     }
 }
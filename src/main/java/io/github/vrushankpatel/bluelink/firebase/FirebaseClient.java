@@ -1,6 +1,7 @@
 package io.github.vrushankpatel.bluelink.firebase;
 
 import com.google.auth.oauth2.GoogleCredentials;
+import com.google.auth.oauth2.ServiceAccountCredentials;
 import com.google.firebase.FirebaseApp;
 import com.google.firebase.FirebaseOptions;
 import com.google.firebase.database.*;
@@ -9,11 +10,18 @@ import com.google.gson.reflect.TypeToken;
 
 import java.io.*;
 import java.lang.reflect.Type;
+import java.net.URI;
 import java.time.Instant;
 import java.util.*;
+import java.util.concurrent.ConcurrentHashMap;
 import java.util.concurrent.CountDownLatch;
 import java.util.concurrent.TimeUnit;
+import java.util.concurrent.atomic.AtomicBoolean;
+import java.util.concurrent.atomic.AtomicInteger;
+import java.util.concurrent.atomic.AtomicLong;
 import java.util.concurrent.atomic.AtomicReference;
+import java.util.function.Consumer;
+import java.util.regex.Pattern;
 
 /**
  * Wraps Firebase Realtime Database operations.
@@ -26,18 +34,66 @@ import java.util.concurrent.atomic.AtomicReference;
  * Database URL resolution order:
  *   1. FIREBASE_DATABASE_URL env var
  *   2. firebase.database.url in classpath "bluelink.properties" (bundled in JAR)
+ *
+ * The URL must look like a Realtime Database endpoint:
+ *   https://<db>.firebaseio.com  or  https://<db>.<region>.firebasedatabase.app
  */
 public class FirebaseClient {
 
     private static final Gson   GSON    = new Gson();
-    private static final String SYSTEM  = "system";
+    private static final String SYSTEM  = Message.SYSTEM_SENDER;
     private static final long   TIMEOUT = 10;
 
+    // Shown in place of a message body no key could open
+    static final String UNDECRYPTABLE = "[Failed to decrypt message]";
+
+    private static final Pattern RTDB_HOST = Pattern.compile(
+            "[a-z0-9-]+\\.firebaseio\\.com|[a-z0-9-]+\\.[a-z0-9-]+\\.firebasedatabase\\.app");
+
     private final FirebaseDatabase db;
 
+    // When false, lastActive is only written on join — others can't tell when you're at the keyboard
+    private volatile boolean sharePresence = true;
+
+    // lastActive writes are coalesced to at most one per room per interval, however many sends
+    // and heartbeats ask for one; a request inside the interval is kept as pending and flushed later
+    private volatile long activityIntervalSeconds = 15;
+    private final Map<String, String> pendingActivity   = new ConcurrentHashMap<>();   // roomId -> userId
+    private final Map<String, Long>   lastActivityWrite = new ConcurrentHashMap<>();   // roomId -> epoch seconds
+
+    // Rooms known to be deleted; nothing more is written to them, or a late write would recreate them
+    private final Set<String> closedRooms = ConcurrentHashMap.newKeySet();
+
+    // Identifies this client among several sessions of the same user
+    private final String sessionId = UUID.randomUUID().toString().substring(0, 8);
+
+    // Mixed into the room key when set; kept in memory only
+    private volatile String passphrase;
+
+    // PBKDF2 output for the current passphrase in a salted room, cached since it's slow to compute
+    private record Stretched(String roomId, byte[] key) {}
+    private volatile Stretched stretched;
+
+    // Operation counters for /netstats
+    private final AtomicLong reads            = new AtomicLong();
+    private final AtomicLong writes           = new AtomicLong();
+    private final AtomicLong messagesFetched  = new AtomicLong();
+    private final AtomicLong participantReads = new AtomicLong();
+    private final AtomicLong sends            = new AtomicLong();
+    private final AtomicLong activityUpdates  = new AtomicLong();
+    private final AtomicLong liveUpdates      = new AtomicLong();
+
+    // Listeners attached and not yet removed, for /debug-mem
+    private final AtomicInteger childListeners = new AtomicInteger();
+    private final AtomicInteger valueListeners = new AtomicInteger();
+
+    // Random key shared out of band (~/.bluelink/keys/<room>.key); null for room-ID keys
+    private volatile byte[] roomSecret;
+
     public FirebaseClient() throws Exception {
         GoogleCredentials credentials = resolveCredentials();
         String dbUrl = resolveDbUrl();
+        validateDbUrl(dbUrl, credentials);
 
         FirebaseOptions options = FirebaseOptions.builder()
                 .setCredentials(credentials)
@@ -51,6 +107,118 @@ public class FirebaseClient {
         this.db = FirebaseDatabase.getInstance();
     }
 
+    /** A client on an already-initialised database; tests pass null and override what they use. */
+    protected FirebaseClient(FirebaseDatabase db) {
+        this.db = db;
+    }
+
+    public void setSharePresence(boolean sharePresence) {
+        this.sharePresence = sharePresence;
+    }
+
+    /** Sets the shortest gap between two lastActive writes for a room (minimum 1 second). */
+    public void setActivityInterval(long seconds) {
+        this.activityIntervalSeconds = Math.max(1, seconds);
+    }
+
+    /**
+     * Sets the passphrase for the next room created; null or empty = room ID only. Joining a
+     * protected room goes through usePassphrase instead, which checks it.
+     */
+    public void setPassphrase(String passphrase) {
+        this.passphrase = passphrase == null || passphrase.isEmpty() ? null : passphrase;
+        this.stretched  = null;
+    }
+
+    public boolean hasPassphrase() { return passphrase != null; }
+
+    /**
+     * Uses passphrase for roomId if it matches the key check in the room's meta; returns false
+     * (changing nothing) if it doesn't. Rooms created before salts have no check and take any.
+     */
+    public boolean usePassphrase(String roomId, String passphrase) throws Exception {
+        RoomMeta meta = getRoomMeta(roomId);
+        if (meta == null || meta.getKdfSalt() == null) {
+            setPassphrase(passphrase);
+            return true;
+        }
+        byte[] key = checkPassphrase(meta, passphrase);
+        if (key == null) return false;
+        this.passphrase = passphrase;
+        this.stretched  = new Stretched(roomId, key);
+        return true;
+    }
+
+    /** True once a checked passphrase is in use for roomId. */
+    public boolean isUnlocked(String roomId) {
+        Stretched s = stretched;
+        return passphrase != null && s != null && s.roomId().equals(roomId);
+    }
+
+    /** The stretched key if passphrase matches the salted room's key check, else null. */
+    static byte[] checkPassphrase(RoomMeta meta, String passphrase) throws Exception {
+        byte[] key = Crypto.stretch(passphrase, Base64.getDecoder().decode(meta.getKdfSalt()));
+        return meta.getKeyCheck() == null || Crypto.matchesKeyCheck(key, meta.getKeyCheck()) ? key : null;
+    }
+
+    /** Snapshot of the operations performed so far. */
+    public NetStats stats() {
+        return new NetStats(reads.get(), writes.get(), messagesFetched.get(), participantReads.get(),
+                sends.get(), activityUpdates.get(), liveUpdates.get());
+    }
+
+    /** Child listeners (message and event streams) currently attached. */
+    public int activeChildListeners() { return childListeners.get(); }
+
+    /** Value listeners (connection and room watchers) currently attached. */
+    public int activeValueListeners() { return valueListeners.get(); }
+
+    /** Counts a listener as attached until the returned handle first runs remove. */
+    private static Runnable counted(AtomicInteger count, Runnable remove) {
+        count.incrementAndGet();
+        AtomicBoolean removed = new AtomicBoolean();
+        return () -> {
+            if (!removed.compareAndSet(false, true)) return;
+            remove.run();
+            count.decrementAndGet();
+        };
+    }
+
+    /** Sets a keyfile secret that replaces the room ID in key derivation; null = derive from the room ID. */
+    public void setRoomSecret(byte[] secret) {
+        this.roomSecret = secret;
+    }
+
+    public boolean hasRoomSecret() { return roomSecret != null; }
+
+    private byte[] roomKey(String roomId) throws Exception {
+        byte[] secret = roomSecret;
+        if (secret == null) return roomIdKey(roomId);
+        byte[] salted = stretchedKey(roomId);
+        return salted != null ? Crypto.combine(secret, salted) : Crypto.deriveKey(secret, passphrase);
+    }
+
+    /** The key without a keyfile: the stretched passphrase in a salted room, else from the room ID. */
+    private byte[] roomIdKey(String roomId) throws Exception {
+        byte[] salted = stretchedKey(roomId);
+        return salted != null ? salted : Crypto.deriveKey(roomId, passphrase);
+    }
+
+    private byte[] stretchedKey(String roomId) {
+        Stretched s = stretched;
+        return passphrase != null && s != null && s.roomId().equals(roomId) ? s.key() : null;
+    }
+
+    /**
+     * Keys to try on a received message, in order: the keyfile's, then the room ID's. Messages
+     * sent before the keyfile was imported, or by members who haven't imported it yet, still
+     * use the room-ID key.
+     */
+    private List<byte[]> decryptionKeys(String roomId) throws Exception {
+        byte[] legacy = roomIdKey(roomId);
+        return roomSecret != null ? List.of(roomKey(roomId), legacy) : List.of(legacy);
+    }
+
     // ── credential / config resolution ───────────────────────────────────────
 
     private static GoogleCredentials resolveCredentials() throws Exception {
@@ -102,84 +270,701 @@ public class FirebaseClient {
         );
     }
 
+    private static void validateDbUrl(String dbUrl, GoogleCredentials credentials) {
+        URI    uri;
+        String host;
+        try {
+            uri  = URI.create(dbUrl.trim());
+            host = uri.getHost() == null ? "" : uri.getHost().toLowerCase();
+        } catch (IllegalArgumentException e) {
+            uri  = null;
+            host = "";
+        }
+
+        // Local emulator (http://localhost:9000?ns=<db>) is the one allowed exception
+        boolean emulator = uri != null && "http".equals(uri.getScheme())
+                && (host.equals("localhost") || host.equals("127.0.0.1"));
+        boolean rtdb     = uri != null && "https".equals(uri.getScheme())
+                && RTDB_HOST.matcher(host).matches();
+        if (!emulator && !rtdb) {
+            throw new IllegalStateException(
+                "Invalid Firebase Database URL: " + dbUrl + "\n" +
+                "Expected one of:\n" +
+                "  https://<project-id>-default-rtdb.firebaseio.com          (us-central1)\n" +
+                "  https://<project-id>-default-rtdb.<region>.firebasedatabase.app  (e.g. europe-west1)\n" +
+                "Copy it from the Realtime Database page of the Firebase console."
+            );
+        }
+
+        // A database from another project usually means mismatched credentials
+        if (rtdb && credentials instanceof ServiceAccountCredentials sa
+                && sa.getProjectId() != null && !host.startsWith(sa.getProjectId().toLowerCase())) {
+            System.err.printf("[Warning] Database %s doesn't look like it belongs to project %s.%n",
+                    host, sa.getProjectId());
+        }
+    }
+
     // ── room operations ───────────────────────────────────────────────────────
 
-    public String createRoom(String userId, String username, String color) throws Exception {
+    /** Creates a room with a random ID. A ttlSeconds of 0 means the room never expires. */
+    public String createRoom(String userId, String username, String color, long ttlSeconds) throws Exception {
         String roomId = String.valueOf(10_000_000 + new Random().nextInt(90_000_000));
-        createRoomWithId(roomId, userId, username, color);
+        createRoomWithId(roomId, userId, username, color, ttlSeconds);
         return roomId;
     }
 
-    public void createRoomWithId(String roomId, String userId, String username, String color) throws Exception {
+    public void createRoomWithId(String roomId, String userId, String username, String color,
+                                 long ttlSeconds) throws Exception {
         long now = Instant.now().getEpochSecond();
+        RoomMeta meta = new RoomMeta(userId, now, ttlSeconds > 0 ? now + ttlSeconds : 0);
+        String phrase = passphrase;
+        if (phrase != null) {
+            // The salt and key check are public; neither helps without the passphrase
+            byte[] salt = Crypto.newSalt();
+            byte[] key  = Crypto.stretch(phrase, salt);
+            meta.setPassphraseProtected(true);
+            meta.setKeyDerivation(Base64.getEncoder().encodeToString(salt), Crypto.keyCheck(key));
+            stretched = new Stretched(roomId, key);
+        }
+        set(roomRef(roomId).child("meta"), toMap(meta));
         set(roomRef(roomId).child("participants").child(userId),
-                toMap(new Participant(username, color, now)));
-        push(roomRef(roomId).child("messages"),
-                toMap(new Message("System", SYSTEM, "#888888", username + " created the room", now)));
+                toMap(new Participant(username, color, now, Participant.MEMBER)));
+        postEvent(roomId, username + " created the room", now);
     }
 
-    public void joinRoom(String roomId, String userId, String username, String color) throws Exception {
+    /**
+     * Registers the user as a participant and announces the join, unless the same
+     * user was already announced within debounceSeconds (flaky reconnects).
+     *
+     * Each client adds itself under the participant's "sessions" node. Returns true if
+     * another session for this user was active within activeWindowSeconds — the same
+     * config open twice — in which case the existing record (and its sessionStart) is
+     * kept and no join is announced.
+     */
+    @SuppressWarnings("unchecked")
+    public boolean joinRoom(String roomId, String userId, String username, String color,
+                            long debounceSeconds, long activeWindowSeconds) throws Exception {
         long now = Instant.now().getEpochSecond();
-        set(roomRef(roomId).child("participants").child(userId),
-                toMap(new Participant(username, color, now)));
-        push(roomRef(roomId).child("messages"),
-                toMap(new Message("System", SYSTEM, "#888888", username + " joined the room", now)));
+        RoomMeta meta = getRoomMeta(roomId);
+        if (meta != null && meta.isExpired(now)) {
+            // Only the creator deletes it; another member's clock may be running fast
+            if (userId.equals(meta.getCreatorId())) deleteRoom(roomId);
+            throw new IllegalStateException("This room has expired.");
+        }
+
+        DatabaseReference participant = roomRef(roomId).child("participants").child(userId);
+        Map<String, Object> existing = get(participant);
+        Map<String, Object> sessions = new LinkedHashMap<>();
+        if (existing != null && existing.get("sessions") instanceof Map) {
+            sessions.putAll((Map<String, Object>) existing.get("sessions"));
+        }
+        boolean elsewhere = !sessions.isEmpty() && now - toLong(existing.get("lastActive")) <= activeWindowSeconds;
+        if (!elsewhere) sessions.clear();   // leftovers from a crashed client
+        sessions.put(sessionId, now);
+
+        // Keep a role the creator assigned, even across leaving; a fresh visit restarts sessionStart
+        String role = meta != null ? meta.getRole(userId) : null;
+        if (role == null && existing != null) role = (String) existing.get("role");   // set before meta/roles existed
+        long   since = elsewhere ? toLong(existing.get("sessionStart")) : now;
+        Map<String, Object> record = toMap(new Participant(username, color, now, since,
+                role != null ? role : Participant.MEMBER));
+        record.put("sessions", sessions);
+        set(participant, record);
+        lastActivityWrite.put(roomId, now);
+        if (elsewhere) return true;
+
+        DatabaseReference announced = roomRef(roomId).child("meta").child("announced").child(userId);
+        Long lastAnnounced = getValue(announced, Long.class);
+        if (lastAnnounced != null && now - lastAnnounced < debounceSeconds) return false;
+
+        postEvent(roomId, username + " joined the room", now);
+        set(announced, now);
+        return false;
     }
 
-    public void leaveRoom(String roomId, String userId) {
+    /**
+     * Ends this client's session. When it was the user's last one, announces the
+     * departure and removes the participant. A non-blank parting message is appended
+     * ("X left: see you tomorrow"); like all system notices it is stored unencrypted.
+     */
+    public void leaveRoom(String roomId, String userId, String parting) {
+        if (closedRooms.contains(roomId)) return;
+        // Taken before any delete, so no flush can write it back afterwards
+        String pending = pendingActivity.remove(roomId);
         try {
-            Map<String, Object> pData = get(roomRef(roomId).child("participants").child(userId));
+            DatabaseReference participant = roomRef(roomId).child("participants").child(userId);
+            delete(participant.child("sessions").child(sessionId));
+            Map<String, Object> pData = get(participant);
+            if (pData != null && pData.get("sessions") instanceof Map<?, ?> others && !others.isEmpty()) {
+                // Still here in another session: write the pending activity now rather than drop it
+                if (pending != null) writeActivity(roomId, pending);
+                return;
+            }
+
             String name = pData != null ? (String) pData.get("name") : "Someone";
-            push(roomRef(roomId).child("messages"),
-                    toMap(new Message("System", SYSTEM, "#888888", name + " left the room",
-                            Instant.now().getEpochSecond())));
-            delete(roomRef(roomId).child("participants").child(userId));
+            String text = parting == null || parting.isBlank() ? name + " left the room" : name + " left: " + parting;
+            postEvent(roomId, text, Instant.now().getEpochSecond());
+            delete(participant);
         } catch (Exception ignored) {}
     }
 
+    /** Adds userId's emoji reaction to a message (reactions/<emoji>/<userId>). */
+    public void addReaction(String roomId, String key, String userId, String emoji) throws Exception {
+        set(roomRef(roomId).child("messages").child(key).child("reactions").child(emoji).child(userId), true);
+    }
+
+    public void removeReaction(String roomId, String key, String userId, String emoji) throws Exception {
+        delete(roomRef(roomId).child("messages").child(key).child("reactions").child(emoji).child(userId));
+    }
+
+    /**
+     * Re-encrypts and replaces the text of one of userId's own messages, marking it edited.
+     * The ownership check is client-side; database rules decide who can really write.
+     */
+    public void editMessage(String roomId, String userId, String key, String newText) throws Exception {
+        DatabaseReference ref = roomRef(roomId).child("messages").child(key);
+        requireOwner(userId, getValue(ref.child("senderId"), String.class), "edit");
+        update(ref, Map.of("text", Crypto.encrypt(newText, roomKey(roomId), roomId, userId), "edited", true, "bound", true));
+    }
+
+    /** Deletes one of userId's own messages (checked client-side only, like edits). */
+    public void deleteMessage(String roomId, String userId, String key) throws Exception {
+        DatabaseReference ref = roomRef(roomId).child("messages").child(key);
+        requireOwner(userId, getValue(ref.child("senderId"), String.class), "delete");
+        delete(ref);
+    }
+
+    /** Throws unless userId sent the message (senderId); action names the refused operation. */
+    static void requireOwner(String userId, String senderId, String action) {
+        if (!userId.equals(senderId)) {
+            throw new IllegalArgumentException("You can only " + action + " your own messages.");
+        }
+    }
+
+    /**
+     * Records a join/leave style notice under the room's "events" node, apart from the
+     * chat. Older clients wrote these into "messages" as system messages; readers still
+     * accept those.
+     */
+    private void postEvent(String roomId, String text, long timestamp) throws Exception {
+        push(roomRef(roomId).child("events"), toMap(new Message("System", SYSTEM, "#888888", text, timestamp)));
+    }
+
+    /** Removes the room entirely: messages, participants and metadata. */
+    public void deleteRoom(String roomId) throws Exception {
+        markClosed(roomId);
+        delete(roomRef(roomId));
+    }
+
+    /** Stops every further write to roomId from this client, for a room that has gone. */
+    public void markClosed(String roomId) {
+        closedRooms.add(roomId);
+        pendingActivity.remove(roomId);
+    }
+
+    /**
+     * Deletes the room's messages sent before olderThan (epoch seconds), except the newest
+     * keep messages, which survive whatever their age so a quiet room isn't left empty.
+     * Returns how many were deleted.
+     */
+    public int pruneMessages(String roomId, long olderThan, int keep) throws Exception {
+        DatabaseReference messages = roomRef(roomId).child("messages");
+        // The old messages plus the newest keep: enough to tell which old ones are protected
+        Map<String, Long> timestamps = new HashMap<>();
+        collectTimestamps(timestamps, get(messages.orderByChild("timestamp").endAt(olderThan - 1)));
+        if (timestamps.isEmpty()) return 0;
+        if (keep > 0) collectTimestamps(timestamps, get(messages.orderByChild("timestamp").limitToLast(keep)));
+
+        Set<String> prunable = selectPrunable(timestamps, olderThan, keep);
+        if (prunable.isEmpty()) return 0;
+
+        // One multi-path update: a null value deletes that child
+        Map<String, Object> removals = new HashMap<>();
+        for (String key : prunable) removals.put(key, null);
+        update(messages, removals);
+        return removals.size();
+    }
+
+    /**
+     * Picks the keys to delete from messages' timestamps: those before olderThan, except the
+     * newest keep overall. Equal timestamps are ordered by key, as push keys are by time.
+     */
+    static Set<String> selectPrunable(Map<String, Long> timestamps, long olderThan, int keep) {
+        List<Map.Entry<String, Long>> newestFirst = new ArrayList<>(timestamps.entrySet());
+        newestFirst.sort(Map.Entry.<String, Long>comparingByValue()
+                .thenComparing(Map.Entry.comparingByKey())
+                .reversed());
+        Set<String> prunable = new HashSet<>();
+        for (int i = Math.max(0, keep); i < newestFirst.size(); i++) {
+            Map.Entry<String, Long> entry = newestFirst.get(i);
+            if (entry.getValue() < olderThan) prunable.add(entry.getKey());
+        }
+        return prunable;
+    }
+
+    @SuppressWarnings("unchecked")
+    private void collectTimestamps(Map<String, Long> into, Map<String, Object> messages) {
+        if (messages == null) return;
+        for (Map.Entry<String, Object> entry : messages.entrySet()) {
+            if (entry.getValue() instanceof Map) {
+                into.put(entry.getKey(), toLong(((Map<String, Object>) entry.getValue()).get("timestamp")));
+            }
+        }
+    }
+
     public boolean checkRoomExists(String roomId) throws Exception {
         Map<String, Object> data = get(roomRef(roomId));
         return data != null && !data.isEmpty();
     }
 
+    /** Returns the room's participants keyed by user ID. */
+    @SuppressWarnings("unchecked")
+    public Map<String, Participant> getParticipants(String roomId) throws Exception {
+        participantReads.incrementAndGet();
+        Map<String, Object> raw = get(roomRef(roomId).child("participants"));
+        if (raw == null || raw.isEmpty()) return Map.of();
+
+        Map<String, Participant> result = new LinkedHashMap<>();
+        for (Map.Entry<String, Object> entry : raw.entrySet()) {
+            if (!(entry.getValue() instanceof Map)) continue;
+            Map<String, Object> map = (Map<String, Object>) entry.getValue();
+            Participant p = new Participant(
+                (String) map.getOrDefault("name", ""),
+                (String) map.getOrDefault("color", "#888888"),
+                toLong(map.get("lastActive")),
+                toLong(map.get("sessionStart")),
+                (String) map.getOrDefault("role", Participant.MEMBER)
+            );
+            p.setLastSeen(toLong(map.get("lastSeen")));
+            result.put(entry.getKey(), p);
+        }
+        return result;
+    }
+
+    /**
+     * Changes a participant's display name and color. A new name is announced as a room
+     * event ("X is now known as Y"); a color-only change is silent.
+     */
+    public void updateParticipant(String roomId, String userId, String name, String color) throws Exception {
+        DatabaseReference participant = roomRef(roomId).child("participants").child(userId);
+        String oldName = getValue(participant.child("name"), String.class);
+        update(participant, Map.of("name", name, "color", color));
+        if (oldName != null && !oldName.equals(name)) {
+            postEvent(roomId, oldName + " is now known as " + name, Instant.now().getEpochSecond());
+        }
+    }
+
+    /**
+     * Sets a participant's role. It's recorded under meta/roles too, which outlives the
+     * participant record, so a demoted user who leaves and rejoins is still an observer.
+     */
+    public void setRole(String roomId, String userId, String role) throws Exception {
+        Map<String, Object> changes = new HashMap<>();
+        changes.put("meta/roles/" + userId, Participant.MEMBER.equals(role) ? null : role);   // null deletes
+        changes.put("participants/" + userId + "/role", role);
+        update(roomRef(roomId), changes);
+    }
+
+    /** Returns the room's metadata, or null for rooms created before metadata existed. */
+    public RoomMeta getRoomMeta(String roomId) throws Exception {
+        Map<String, Object> raw = get(roomRef(roomId).child("meta"));
+        if (raw == null) return null;
+        return fromMap(raw, RoomMeta.class);
+    }
+
+    public void setBroadcast(String roomId, boolean broadcast) throws Exception {
+        update(roomRef(roomId).child("meta"), Map.of("broadcast", broadcast));
+    }
+
+    /** Sets the room's welcome banner; null or blank removes it. */
+    public void setWelcome(String roomId, String welcome) throws Exception {
+        DatabaseReference ref = roomRef(roomId).child("meta").child("welcome");
+        if (welcome == null || welcome.isBlank()) delete(ref);
+        else set(ref, welcome);
+    }
+
+    public void setTheme(String roomId, String theme) throws Exception {
+        DatabaseReference ref = roomRef(roomId).child("meta").child("theme");
+        if (theme == null) delete(ref);
+        else set(ref, theme);
+    }
+
+    // ── moderation ────────────────────────────────────────────────────────────
+
+    public void reportMessage(String roomId, String messageKey, String reporterId, String reason) throws Exception {
+        push(roomRef(roomId).child("meta").child("reports"),
+                toMap(new Report(messageKey, reporterId, reason, Instant.now().getEpochSecond())));
+    }
+
+    /**
+     * Returns all reports for the room, oldest first.
+     * Only the creator's client asks for these — the database rules should
+     * restrict meta/reports to the creator for this to be actually enforced.
+     */
+    @SuppressWarnings("unchecked")
+    public List<Report> getReports(String roomId) throws Exception {
+        Map<String, Object> raw = get(roomRef(roomId).child("meta").child("reports"));
+        if (raw == null || raw.isEmpty()) return List.of();
+
+        List<Report> result = new ArrayList<>();
+        for (Object value : raw.values()) {
+            if (!(value instanceof Map)) continue;
+            Map<String, Object> map = (Map<String, Object>) value;
+            result.add(new Report(
+                (String) map.getOrDefault("messageKey", ""),
+                (String) map.getOrDefault("reporterId", ""),
+                (String) map.getOrDefault("reason", ""),
+                toLong(map.get("timestamp"))
+            ));
+        }
+        result.sort(Comparator.comparingLong(Report::getTimestamp));
+        return result;
+    }
+
     // ── messaging ─────────────────────────────────────────────────────────────
 
+    /**
+     * Encrypts and posts a message.
+     * Throws ReadOnlyException if the sender is an observer or the room is in
+     * broadcast mode and the sender isn't the creator (client-side enforcement).
+     */
     public void sendMessage(String roomId, String userId, String username,
-                            String color, String text) throws Exception {
+                            String color, String text, String clientId) throws Exception {
+        sendMessage(roomId, userId, username, color, text, clientId, 0);
+    }
+
+    /** As above, for a message that disappears ttlSeconds after sending (0 = never). */
+    public void sendMessage(String roomId, String userId, String username,
+                            String color, String text, String clientId, long ttlSeconds) throws Exception {
+        sendMessage(roomId, userId, username, color, text, clientId, ttlSeconds, null);
+    }
+
+    /** As above, with a message type such as Message.TYPE_ACTION (null = ordinary). Only the text is encrypted. */
+    public void sendMessage(String roomId, String userId, String username, String color, String text,
+                            String clientId, long ttlSeconds, String type) throws Exception {
+        if (closedRooms.contains(roomId)) throw new IllegalStateException("This room no longer exists.");
+        checkCanPost(roomId, userId);
         long now = Instant.now().getEpochSecond();
-        push(roomRef(roomId).child("messages"),
-                toMap(new Message(username, userId, color, Crypto.encrypt(text, roomId), now)));
-        update(roomRef(roomId).child("participants").child(userId), Map.of("lastActive", now));
+        Message msg = seal(username, userId, color, text, roomKey(roomId), roomId, now);
+        msg.setClientId(clientId);
+        if (ttlSeconds > 0) msg.setExpiresAt(now + ttlSeconds);
+        msg.setType(type);
+        push(roomRef(roomId).child("messages"), toMap(msg));
+        sends.incrementAndGet();
+        touchActivity(roomId, userId);
+    }
+
+    /**
+     * Posts a message to a room other than the one this client is keyed for, without joining
+     * it. The key comes from that room's keyfile secret (null = its room ID) and, for a
+     * passphrase-protected room, passphrase; a wrong one throws IllegalArgumentException.
+     */
+    public void crossPost(String roomId, String userId, String username, String color, String text,
+                          String type, byte[] secret, String passphrase) throws Exception {
+        RoomMeta meta = getRoomMeta(roomId);
+        String role = meta != null ? meta.getRole(userId) : null;
+        if (role == null) role = getValue(roomRef(roomId).child("participants").child(userId).child("role"), String.class);
+        checkCanPost(userId, role, meta != null && meta.isBroadcast(), meta != null ? meta.getCreatorId() : null);
+        byte[] key = crossPostKey(roomId, meta, secret, passphrase);
+        Message msg = seal(username, userId, color, text, key, roomId, Instant.now().getEpochSecond());
+        msg.setClientId(UUID.randomUUID().toString());
+        msg.setType(type);
+        push(roomRef(roomId).child("messages"), toMap(msg));
+        sends.incrementAndGet();
+    }
+
+    /** The key roomKey would give a member of roomId holding secret and passphrase. */
+    static byte[] crossPostKey(String roomId, RoomMeta meta, byte[] secret, String passphrase) throws Exception {
+        if (meta == null || !meta.isPassphraseProtected()) {
+            return secret != null ? Crypto.deriveKey(secret, null) : Crypto.deriveKey(roomId, null);
+        }
+        if (passphrase == null || passphrase.isEmpty()) {
+            throw new IllegalStateException("room " + roomId + " needs a passphrase");
+        }
+        if (meta.getKdfSalt() == null) {
+            return secret != null ? Crypto.deriveKey(secret, passphrase) : Crypto.deriveKey(roomId, passphrase);
+        }
+        byte[] stretched = checkPassphrase(meta, passphrase);
+        if (stretched == null) throw new IllegalArgumentException("Wrong passphrase for room " + roomId + ".");
+        return secret != null ? Crypto.combine(secret, stretched) : stretched;
     }
 
-    public List<Message> pollMessages(String roomId, long afterTimestamp) throws Exception {
-        Map<String, Object> raw = get(roomRef(roomId).child("messages"));
+    /**
+     * Throws ReadOnlyException if userId may not post in the room. Reads only the fields
+     * that decide it, not the whole meta node with its reports.
+     */
+    private void checkCanPost(String roomId, String userId) throws Exception {
+        DatabaseReference meta = roomRef(roomId).child("meta");
+        String role = getValue(meta.child("roles").child(userId), String.class);
+        if (role == null) role = getValue(roomRef(roomId).child("participants").child(userId).child("role"), String.class);
+        boolean broadcast = Boolean.TRUE.equals(getValue(meta.child("broadcast"), Boolean.class));
+        String creatorId  = broadcast ? getValue(meta.child("creatorId"), String.class) : null;
+        checkCanPost(userId, role, broadcast, creatorId);
+    }
+
+    private static void checkCanPost(String userId, String role, boolean broadcast, String creatorId)
+            throws ReadOnlyException {
+        if (Participant.OBSERVER.equals(role)) {
+            throw new ReadOnlyException("You are an observer in this room and can't post.");
+        }
+        if (broadcast && !userId.equals(creatorId)) {
+            throw new ReadOnlyException("This room is in broadcast mode — only the creator can post.");
+        }
+    }
+
+    /** Posts an already-sent message (e.g. from an export) under its original timestamp, with the same checks as sendMessage. */
+    public void importMessage(String roomId, String userId, String username, String color,
+                              String text, long timestamp) throws Exception {
+        if (closedRooms.contains(roomId)) throw new IllegalStateException("This room no longer exists.");
+        checkCanPost(roomId, userId);
+        Message msg = seal(username, userId, color, text, roomKey(roomId), roomId, timestamp);
+        msg.setClientId(UUID.randomUUID().toString());
+        push(roomRef(roomId).child("messages"), toMap(msg));
+    }
+
+    /** A new message with its text encrypted for roomId and userId, marked bound so readers insist on that. */
+    private static Message seal(String username, String userId, String color, String text, byte[] key,
+                                String roomId, long timestamp) throws Exception {
+        Message msg = new Message(username, userId, color, Crypto.encrypt(text, key, roomId, userId), timestamp);
+        msg.setBound(true);
+        return msg;
+    }
+
+    /**
+     * Returns messages from push key afterKey on (null = from the start), oldest first.
+     * The boundary message itself is included; callers drop the repeat by key. Push keys
+     * are unique and ordered, so nothing is lost or repeated when several messages share
+     * a second. At most limit messages (the newest) are downloaded and decrypted, so a
+     * spammed room can't exhaust memory; any older ones after afterKey are skipped, which
+     * callers can tell from a full result that doesn't start at afterKey.
+     */
+    public List<Message> pollMessages(String roomId, String afterKey, int limit) throws Exception {
+        return fetchMessages(roomId, fromKey(roomRef(roomId).child("messages"), afterKey).limitToLast(limit));
+    }
+
+    /** Children ordered by push key, starting at key (inclusive); null = all of them. */
+    private static Query fromKey(DatabaseReference ref, String key) {
+        Query query = ref.orderByKey();
+        return key != null ? query.startAt(key) : query;
+    }
+
+    /**
+     * Returns up to limit messages before the one with push key beforeKey, oldest first —
+     * the page of scrollback preceding what's shown (null = the latest page). Keys are
+     * unique, so unlike timestamps a page boundary can't split or repeat a busy second.
+     */
+    public List<Message> getMessagesBefore(String roomId, String beforeKey, int limit) throws Exception {
+        Query query = roomRef(roomId).child("messages").orderByKey();
+        if (beforeKey == null) return fetchMessages(roomId, query.limitToLast(limit));
+
+        // endAt is inclusive: fetch one extra and drop the boundary message itself
+        List<Message> page = new ArrayList<>(fetchMessages(roomId, query.endAt(beforeKey).limitToLast(limit + 1)));
+        page.removeIf(msg -> beforeKey.equals(msg.getKey()));
+        return page.size() > limit ? page.subList(page.size() - limit, page.size()) : page;
+    }
+
+    private List<Message> fetchMessages(String roomId, Query query) throws Exception {
+        Map<String, Object> raw = get(query);
         if (raw == null || raw.isEmpty()) return List.of();
+        messagesFetched.addAndGet(raw.size());
 
         List<Message> result = new ArrayList<>();
         for (Map.Entry<String, Object> entry : raw.entrySet()) {
             Message msg = toMessage(entry.getValue());
-            if (msg != null && msg.getTimestamp() > afterTimestamp) {
+            if (msg != null) {
+                msg.setKey(entry.getKey());
                 result.add(decryptMsg(msg, roomId));
             }
         }
-        result.sort(Comparator.comparingLong(Message::getTimestamp));
+        result.sort(Message.CHRONOLOGICAL);
         return result;
     }
 
-    public List<Message> getInitialMessages(String roomId) throws Exception {
-        return pollMessages(roomId, 0);
+    /**
+     * Streams messages from push key afterKey on as they are added (child_added), so only
+     * new messages cross the wire; edits and deletions arrive through onChanged and
+     * onRemoved (by push key). Callbacks run
+     * on the SDK's event thread; onFailure
+     * is called if the database cancels the listener (e.g. permission change), after
+     * which nothing more is delivered. Running the returned handle unsubscribes.
+     */
+    public Runnable listenForMessages(String roomId, String afterKey, Consumer<Message> onMessage,
+                                      Consumer<Message> onChanged, Consumer<String> onRemoved,
+                                      Consumer<Exception> onFailure) {
+        ChildEventListener listener = new ChildEventListener() {
+            @Override
+            public void onChildAdded(DataSnapshot snapshot, String previousChildName) {
+                liveUpdates.incrementAndGet();
+                Message msg = decode(snapshot);
+                if (msg != null) onMessage.accept(msg);
+            }
+
+            @Override
+            public void onChildChanged(DataSnapshot snapshot, String previousChildName) {
+                liveUpdates.incrementAndGet();
+                Message msg = decode(snapshot);
+                if (msg != null) onChanged.accept(msg);
+            }
+
+            private Message decode(DataSnapshot snapshot) {
+                Message msg = toMessage(snapshot.getValue());
+                if (msg == null) return null;
+                msg.setKey(snapshot.getKey());
+                return decryptMsg(msg, roomId);
+            }
+
+            @Override
+            public void onChildRemoved(DataSnapshot snapshot) {
+                liveUpdates.incrementAndGet();
+                onRemoved.accept(snapshot.getKey());
+            }
+
+            @Override public void onChildMoved(DataSnapshot snapshot, String previousChildName) {}
+
+            @Override
+            public void onCancelled(DatabaseError error) {
+                onFailure.accept(error.toException());
+            }
+        };
+        Query query = fromKey(roomRef(roomId).child("messages"), afterKey);
+        query.addChildEventListener(listener);
+        return counted(childListeners, () -> query.removeEventListener(listener));
+    }
+
+    /** Returns room events (joins, departures) from push key afterKey on, oldest first; see pollMessages. */
+    public List<Message> pollEvents(String roomId, String afterKey, int limit) throws Exception {
+        return fetchMessages(roomId, fromKey(roomRef(roomId).child("events"), afterKey).limitToLast(limit));
+    }
+
+    /** Returns the room's latest events, oldest first. */
+    public List<Message> getEvents(String roomId, int limit) throws Exception {
+        return pollEvents(roomId, null, limit);
+    }
+
+    /** Streams new room events as they're added; see listenForMessages. */
+    public Runnable listenForEvents(String roomId, String afterKey, Consumer<Message> onEvent) {
+        ChildEventListener listener = new ChildEventListener() {
+            @Override
+            public void onChildAdded(DataSnapshot snapshot, String previousChildName) {
+                liveUpdates.incrementAndGet();
+                Message msg = toMessage(snapshot.getValue());
+                if (msg == null) return;
+                msg.setKey(snapshot.getKey());
+                onEvent.accept(msg);
+            }
+
+            @Override public void onChildChanged(DataSnapshot snapshot, String previousChildName) {}
+            @Override public void onChildRemoved(DataSnapshot snapshot) {}
+            @Override public void onChildMoved(DataSnapshot snapshot, String previousChildName) {}
+            @Override public void onCancelled(DatabaseError error) {}
+        };
+        Query query = fromKey(roomRef(roomId).child("events"), afterKey);
+        query.addChildEventListener(listener);
+        return counted(childListeners, () -> query.removeEventListener(listener));
     }
 
+    /**
+     * Reports the client's connection to the database (.info/connected) as it changes.
+     * The listener runs on the SDK's event thread; running the returned handle stops it.
+     */
+    public Runnable watchConnection(Consumer<Boolean> listener) {
+        DatabaseReference ref = db.getReference(".info/connected");
+        ValueEventListener watcher = new ValueEventListener() {
+            @Override
+            public void onDataChange(DataSnapshot snapshot) {
+                listener.accept(Boolean.TRUE.equals(snapshot.getValue(Boolean.class)));
+            }
+
+            @Override
+            public void onCancelled(DatabaseError error) {}
+        };
+        ref.addValueEventListener(watcher);
+        return counted(valueListeners, () -> ref.removeEventListener(watcher));
+    }
+
+    /**
+     * Calls onGone (on the SDK's event thread) once the room is deleted — destroyed by its
+     * creator or expired — and stops this client writing to it. Rooms created before
+     * metadata existed can't be watched. Running the returned handle stops watching.
+     */
+    public Runnable watchRoom(String roomId, Runnable onGone) {
+        // A single small value that every room with metadata has, so changes elsewhere in meta aren't downloaded
+        DatabaseReference ref = roomRef(roomId).child("meta").child("createdAt");
+        AtomicBoolean existed = new AtomicBoolean();
+        ValueEventListener watcher = new ValueEventListener() {
+            @Override
+            public void onDataChange(DataSnapshot snapshot) {
+                if (snapshot.exists()) {
+                    existed.set(true);
+                } else if (existed.get()) {
+                    markClosed(roomId);
+                    onGone.run();
+                }
+            }
+
+            @Override
+            public void onCancelled(DatabaseError error) {}
+        };
+        ref.addValueEventListener(watcher);
+        return counted(valueListeners, () -> ref.removeEventListener(watcher));
+    }
+
+    /** Returns up to limit of the room's most recent messages, oldest first. */
+    public List<Message> getInitialMessages(String roomId, int limit) throws Exception {
+        return pollMessages(roomId, null, limit);
+    }
+
+    /** Refreshes lastActive (the presence heartbeat); coalesced with other updates, see touchActivity. */
     public void updateActivity(String roomId, String userId) throws Exception {
-        update(roomRef(roomId).child("participants").child(userId),
-                Map.of("lastActive", Instant.now().getEpochSecond()));
+        touchActivity(roomId, userId);
+    }
+
+    /**
+     * Writes lastActive now if the last write for this room is at least activityIntervalSeconds
+     * old; otherwise marks it pending, and flushActivity writes it once the interval is up.
+     */
+    private void touchActivity(String roomId, String userId) throws Exception {
+        if (!sharePresence || closedRooms.contains(roomId)) return;
+        Long last = lastActivityWrite.get(roomId);
+        if (last == null || Instant.now().getEpochSecond() - last >= activityIntervalSeconds) {
+            writeActivity(roomId, userId);
+        } else {
+            pendingActivity.put(roomId, userId);
+        }
+    }
+
+    private void writeActivity(String roomId, String userId) throws Exception {
+        if (closedRooms.contains(roomId)) return;
+        long now = Instant.now().getEpochSecond();
+        pendingActivity.remove(roomId, userId);
+        lastActivityWrite.put(roomId, now);
+        activityUpdates.incrementAndGet();
+        update(roomRef(roomId).child("participants").child(userId), Map.of("lastActive", now));
+    }
+
+    /**
+     * Writes each pending lastActive whose interval is up. The session calls this from its
+     * heartbeat thread, so it stops before the session leaves and can't recreate the participant.
+     */
+    public void flushActivity() {
+        long now = Instant.now().getEpochSecond();
+        pendingActivity.forEach((roomId, userId) -> {
+            if (now - lastActivityWrite.getOrDefault(roomId, 0L) < activityIntervalSeconds) return;
+            try { writeActivity(roomId, userId); } catch (Exception ignored) {}
+        });
+    }
+
+    /** Records the timestamp of the newest message this user has been shown. */
+    public void markSeen(String roomId, String userId, long timestamp) throws Exception {
+        if (!sharePresence || closedRooms.contains(roomId)) return;
+        update(roomRef(roomId).child("participants").child(userId), Map.of("lastSeen", timestamp));
     }
 
     // ── Firebase sync helpers ─────────────────────────────────────────────────
 
     @SuppressWarnings("unchecked")
-    private Map<String, Object> get(DatabaseReference ref) throws Exception {
+    private Map<String, Object> get(Query ref) throws Exception {
+        reads.incrementAndGet();
         CountDownLatch latch = new CountDownLatch(1);
         AtomicReference<Map<String, Object>> result = new AtomicReference<>();
         AtomicReference<Exception> error = new AtomicReference<>();
@@ -192,7 +977,22 @@ public class FirebaseClient {
         return result.get();
     }
 
-    private void set(DatabaseReference ref, Map<String, Object> value) throws Exception {
+    private <T> T getValue(DatabaseReference ref, Class<T> type) throws Exception {
+        reads.incrementAndGet();
+        CountDownLatch latch = new CountDownLatch(1);
+        AtomicReference<T> result = new AtomicReference<>();
+        AtomicReference<Exception> error = new AtomicReference<>();
+        ref.addListenerForSingleValueEvent(new ValueEventListener() {
+            @Override public void onDataChange(DataSnapshot s) { result.set(s.getValue(type)); latch.countDown(); }
+            @Override public void onCancelled(DatabaseError e) { error.set(e.toException()); latch.countDown(); }
+        });
+        if (!latch.await(TIMEOUT, TimeUnit.SECONDS)) throw new Exception("Firebase read timed out");
+        if (error.get() != null) throw error.get();
+        return result.get();
+    }
+
+    private void set(DatabaseReference ref, Object value) throws Exception {
+        writes.incrementAndGet();
         CountDownLatch latch = new CountDownLatch(1);
         AtomicReference<Exception> error = new AtomicReference<>();
         ref.setValue(value, (e, r) -> { if (e != null) error.set(e.toException()); latch.countDown(); });
@@ -201,6 +1001,7 @@ public class FirebaseClient {
     }
 
     private void push(DatabaseReference ref, Map<String, Object> value) throws Exception {
+        writes.incrementAndGet();
         CountDownLatch latch = new CountDownLatch(1);
         AtomicReference<Exception> error = new AtomicReference<>();
         ref.push().setValue(value, (e, r) -> { if (e != null) error.set(e.toException()); latch.countDown(); });
@@ -209,6 +1010,7 @@ public class FirebaseClient {
     }
 
     private void update(DatabaseReference ref, Map<String, Object> value) throws Exception {
+        writes.incrementAndGet();
         CountDownLatch latch = new CountDownLatch(1);
         AtomicReference<Exception> error = new AtomicReference<>();
         ref.updateChildren(value, (e, r) -> { if (e != null) error.set(e.toException()); latch.countDown(); });
@@ -217,6 +1019,7 @@ public class FirebaseClient {
     }
 
     private void delete(DatabaseReference ref) throws Exception {
+        writes.incrementAndGet();
         CountDownLatch latch = new CountDownLatch(1);
         AtomicReference<Exception> error = new AtomicReference<>();
         ref.removeValue((e, r) -> { if (e != null) error.set(e.toException()); latch.countDown(); });
@@ -234,13 +1037,28 @@ public class FirebaseClient {
     private Message toMessage(Object raw) {
         if (!(raw instanceof Map)) return null;
         Map<String, Object> map = (Map<String, Object>) raw;
-        return new Message(
+        Message msg = new Message(
             (String) map.getOrDefault("sender", ""),
             (String) map.getOrDefault("senderId", ""),
             (String) map.getOrDefault("color", "#888888"),
             (String) map.getOrDefault("text", ""),
             toLong(map.get("timestamp"))
         );
+        msg.setClientId((String) map.get("clientId"));
+        msg.setExpiresAt(toLong(map.get("expiresAt")));
+        if (Boolean.TRUE.equals(map.get("edited"))) msg.setEdited(true);
+        if (Boolean.TRUE.equals(map.get("bound")))  msg.setBound(true);
+        if (map.get("type") instanceof String type) msg.setType(type);
+        if (map.get("reactions") instanceof Map<?, ?> reactions) {
+            Map<String, List<String>> byEmoji = new TreeMap<>();
+            reactions.forEach((emoji, users) -> {
+                if (users instanceof Map<?, ?> u && !u.isEmpty()) {
+                    byEmoji.put((String) emoji, u.keySet().stream().map(String::valueOf).toList());
+                }
+            });
+            msg.setReactions(byEmoji);
+        }
+        return msg;
     }
 
     private long toLong(Object v) {
@@ -250,18 +1068,59 @@ public class FirebaseClient {
         return 0L;
     }
 
+    private <T> T fromMap(Map<String, Object> map, Class<T> type) {
+        return GSON.fromJson(GSON.toJson(map), type);
+    }
+
     private Map<String, Object> toMap(Object obj) {
         Type type = new TypeToken<Map<String, Object>>(){}.getType();
         return GSON.fromJson(GSON.toJson(obj), type);
     }
 
     private Message decryptMsg(Message msg, String roomId) {
-        if (SYSTEM.equals(msg.getSenderId())) return msg;
+        List<byte[]> keys;
         try {
-            msg.setText(Crypto.decrypt(msg.getText(), roomId));
+            keys = decryptionKeys(roomId);
         } catch (Exception e) {
-            msg.setText("[Failed to decrypt message]");
+            keys = List.of();
+        }
+        return decryptWith(msg, roomId, keys);
+    }
+
+    /** Tries the current keys on a message no key could open before; true if it reads now. */
+    public boolean redecrypt(Message msg, String roomId) {
+        if (!msg.isUndecryptable()) return false;
+        msg.restoreCiphertext();
+        return !decryptMsg(msg, roomId).isUndecryptable();
+    }
+
+    /**
+     * Decrypts msg in place with the first of keys that opens it, or marks it undecryptable.
+     * A body that only opens without the room and sender bound in is accepted just for
+     * messages not marked bound (older clients), and is then marked unverified.
+     */
+    static Message decryptWith(Message msg, String roomId, List<byte[]> keys) {
+        if (msg.isSystem()) return msg;
+        for (byte[] key : keys) {
+            try {
+                msg.setText(Crypto.decrypt(msg.getText(), key, roomId, msg.getSenderId()));
+                return msg;
+            } catch (Exception e) {
+                // Not this key; try the next
+            }
+        }
+        if (!msg.isBound()) {
+            for (byte[] key : keys) {
+                try {
+                    msg.setText(Crypto.decryptLegacy(msg.getText(), key));
+                    msg.markUnverified();
+                    return msg;
+                } catch (Exception e) {
+                    // Not this key either
+                }
+            }
         }
+        msg.markUndecryptable(UNDECRYPTABLE);
         return msg;
     }
 }
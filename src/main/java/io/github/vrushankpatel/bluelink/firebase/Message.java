@@ -1,15 +1,58 @@
 package io.github.vrushankpatel.bluelink.firebase;
 
+import java.util.Comparator;
+import java.util.List;
+import java.util.Map;
+
 /**
  * Represents a single chat message stored in Firebase.
  */
 public class Message {
 
+    /** Sender ID of join/leave/created notices written by the client itself. */
+    public static final String SYSTEM_SENDER = "system";
+
+    /** Type of a /me message, shown as "* Alice waves". */
+    public static final String TYPE_ACTION = "action";
+
+    /** Type of a /hl message, shown on an accent background so it stands out. */
+    public static final String TYPE_HIGHLIGHT = "highlight";
+
+    /**
+     * Display order: by push key, then timestamp. Push keys are assigned in write order
+     * and sort chronologically, so a sender's skewed clock can't reorder the room;
+     * the timestamp is only for display (and for messages without a key).
+     */
+    public static final Comparator<Message> CHRONOLOGICAL = Comparator
+            .comparing(Message::getKey, Comparator.nullsLast(Comparator.naturalOrder()))
+            .thenComparingLong(Message::getTimestamp);
+
     private String sender;
     private String senderId;
     private String color;
     private String text;
     private long   timestamp;
+    private String clientId;   // random ID chosen by the sender, used to drop duplicate sends
+    private Long   expiresAt;  // epoch seconds after which a /timed message disappears; null = never
+    private Boolean edited;    // true once the sender has changed the text; null = never
+    private String type;       // TYPE_ACTION for /me, TYPE_HIGHLIGHT for /hl; null = ordinary (older clients ignore it)
+    private Boolean bound;     // true: body sealed with room and sender as associated data; null = may predate that
+
+    // Firebase push key — assigned by the database, never serialized
+    private transient String key;
+
+    // Set when the body couldn't be decrypted and text holds a placeholder
+    private transient boolean undecryptable;
+    private transient String  ciphertext;   // the body it replaced, kept to try another key
+
+    // Set when the body only opened as a legacy one, so it may have been copied from another room or sender
+    private transient boolean unverified;
+
+    // emoji -> IDs of users who reacted; written under the message's "reactions" node, not with it
+    private transient Map<String, List<String>> reactions = Map.of();
+
+    // Set locally once the message has been removed from the room
+    private transient boolean deleted;
 
     public Message() {}
 
@@ -26,6 +69,64 @@ public class Message {
     public String getColor()     { return color; }
     public String getText()      { return text; }
     public long   getTimestamp() { return timestamp; }
+    public String getKey()       { return key; }
+    public String getClientId()  { return clientId; }
+    public long   getExpiresAt() { return expiresAt != null ? expiresAt : 0; }
+    public boolean isEdited()    { return Boolean.TRUE.equals(edited); }
+    public String getType()      { return type; }
+    public boolean isAction()    { return TYPE_ACTION.equals(type); }
+    public boolean isHighlight() { return TYPE_HIGHLIGHT.equals(type); }
+    public Map<String, List<String>> getReactions() { return reactions; }
+
+    public boolean isSystem() { return SYSTEM_SENDER.equals(senderId); }
+    public boolean isUndecryptable() { return undecryptable; }
+    public boolean isBound()         { return Boolean.TRUE.equals(bound); }
+    public boolean isUnverified()    { return unverified; }
+    public boolean isDeleted()       { return deleted; }
 
     public void setText(String text) { this.text = text; }
+    public void setKey(String key)   { this.key = key; }
+    public void setClientId(String clientId) { this.clientId = clientId; }
+    public void setExpiresAt(long expiresAt) { this.expiresAt = expiresAt > 0 ? expiresAt : null; }
+    public void setEdited(boolean edited)    { this.edited = edited ? Boolean.TRUE : null; }
+    public void setType(String type)         { this.type = type; }
+    public void setBound(boolean bound)      { this.bound = bound ? Boolean.TRUE : null; }
+
+    void setReactions(Map<String, List<String>> reactions) { this.reactions = reactions; }
+
+    public void markDeleted() {
+        this.text    = "[message deleted]";
+        this.deleted = true;
+    }
+
+    void markUndecryptable(String placeholder) {
+        this.ciphertext    = text;
+        this.text          = placeholder;
+        this.undecryptable = true;
+    }
+
+    void markUnverified() {
+        this.unverified = true;
+    }
+
+    /**
+     * Takes what can change after sending (text, edited flag, reactions) from a newer copy
+     * of this message, so anything holding on to this object sees the change.
+     */
+    public void updateFrom(Message newer) {
+        this.text          = newer.text;
+        this.edited        = newer.edited;
+        this.reactions     = newer.reactions;
+        this.undecryptable = newer.undecryptable;
+        this.ciphertext    = newer.ciphertext;
+        this.unverified    = newer.unverified;
+        this.bound         = newer.bound;
+    }
+
+    /** Puts back the body a failed decryption replaced, so another key can be tried. */
+    void restoreCiphertext() {
+        if (!undecryptable) return;
+        this.text          = ciphertext;
+        this.undecryptable = false;
+    }
 }
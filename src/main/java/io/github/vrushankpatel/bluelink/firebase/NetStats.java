@@ -0,0 +1,17 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+/**
+ * Database operations performed by this client since it started, for /netstats.
+ *
+ * reads and writes count every round trip; the other fields break down the
+ * ones that usually dominate a room's usage.
+ */
+public record NetStats(
+        long reads,             // one-off reads (polls, lookups)
+        long writes,            // sets, pushes, updates and deletes
+        long messagesFetched,   // messages and events downloaded by polls and history loads
+        long participantReads,  // full participant list reads (/who, /find, ...)
+        long sends,             // messages posted
+        long activityUpdates,   // presence heartbeats
+        long liveUpdates        // changes pushed by the live listeners
+) {}
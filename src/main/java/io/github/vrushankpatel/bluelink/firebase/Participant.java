@@ -5,19 +5,44 @@ package io.github.vrushankpatel.bluelink.firebase;
  */
 public class Participant {
 
+    public static final String MEMBER   = "member";
+    public static final String OBSERVER = "observer";   // present, but can't post
+
     private String name;
     private String color;
     private long   lastActive;
+    private long   sessionStart;   // when the current visit began; 0 from older clients
+    private long   lastSeen;       // timestamp of the newest message they've been shown
+    private String role = MEMBER;
 
     public Participant() {}
 
-    public Participant(String name, String color, long lastActive) {
-        this.name       = name;
-        this.color      = color;
-        this.lastActive = lastActive;
+    /** A fresh record whose session starts at lastActive. */
+    public Participant(String name, String color, long lastActive, String role) {
+        this(name, color, lastActive, lastActive, role);
+    }
+
+    public Participant(String name, String color, long lastActive, long sessionStart, String role) {
+        this.name         = name;
+        this.color        = color;
+        this.lastActive   = lastActive;
+        this.sessionStart = sessionStart;
+        this.role         = role;
     }
 
     public String getName()       { return name; }
     public String getColor()      { return color; }
     public long   getLastActive() { return lastActive; }
+    public long   getSessionStart() { return sessionStart; }
+    public long   getLastSeen()     { return lastSeen; }
+    public String getRole()       { return role; }
+
+    public void setLastSeen(long lastSeen) { this.lastSeen = lastSeen; }
+
+    public boolean isObserver() { return OBSERVER.equals(role); }
+
+    /** True if the participant was active within timeoutSeconds of now (epoch seconds). */
+    public boolean isOnline(long now, long timeoutSeconds) {
+        return now - lastActive <= timeoutSeconds;
+    }
 }
@@ -0,0 +1,11 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+/**
+ * Thrown when the sender isn't allowed to post in the room.
+ */
+public class ReadOnlyException extends Exception {
+
+    public ReadOnlyException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,26 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+/**
+ * A participant's report flagging a message for the room creator.
+ */
+public class Report {
+
+    private String messageKey;
+    private String reporterId;
+    private String reason;
+    private long   timestamp;
+
+    public Report() {}
+
+    public Report(String messageKey, String reporterId, String reason, long timestamp) {
+        this.messageKey = messageKey;
+        this.reporterId = reporterId;
+        this.reason     = reason;
+        this.timestamp  = timestamp;
+    }
+
+    public String getMessageKey() { return messageKey; }
+    public String getReporterId() { return reporterId; }
+    public String getReason()     { return reason; }
+    public long   getTimestamp()  { return timestamp; }
+}
@@ -0,0 +1,50 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import java.util.Map;
+
+/**
+ * Room-level metadata (creator, moderation state) stored under the room's "meta" node.
+ */
+public class RoomMeta {
+
+    private String  creatorId;
+    private long    createdAt;
+    private boolean broadcast;   // only the creator may post
+    private long    expiresAt;   // epoch seconds, 0 = never
+    private boolean passphraseProtected;   // key also needs a passphrase (never stored)
+    private String  welcome;     // banner shown to joiners before history, unencrypted; null = none
+    private String  theme;       // "#rrggbb" accent for the room's rules and banner frame; null = default
+    private String  kdfSalt;     // base64 PBKDF2 salt of a passphrase room; null = created before salts
+    private String  keyCheck;    // base64 MAC that tells a right passphrase from a wrong one
+    private Map<String, String> roles;   // userId -> role the creator set, kept here so leaving doesn't reset it
+
+    public RoomMeta() {}
+
+    public RoomMeta(String creatorId, long createdAt, long expiresAt) {
+        this.creatorId = creatorId;
+        this.createdAt = createdAt;
+        this.expiresAt = expiresAt;
+    }
+
+    public String  getCreatorId() { return creatorId; }
+    public long    getCreatedAt() { return createdAt; }
+    public boolean isBroadcast()  { return broadcast; }
+    public long    getExpiresAt() { return expiresAt; }
+    public String  getWelcome()   { return welcome; }
+    public String  getTheme()     { return theme; }
+    public String  getKdfSalt()   { return kdfSalt; }
+    public String  getKeyCheck()  { return keyCheck; }
+    public boolean isPassphraseProtected() { return passphraseProtected; }
+
+    /** The role the creator gave userId, or null if they never changed it. */
+    public String getRole(String userId) { return roles == null ? null : roles.get(userId); }
+
+    public void setPassphraseProtected(boolean passphraseProtected) { this.passphraseProtected = passphraseProtected; }
+
+    public void setKeyDerivation(String kdfSalt, String keyCheck) {
+        this.kdfSalt  = kdfSalt;
+        this.keyCheck = keyCheck;
+    }
+
+    public boolean isExpired(long now) { return expiresAt > 0 && now >= expiresAt; }
+}
@@ -0,0 +1,58 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+
+class CommandArgsTest {
+
+    @Test
+    void splitsOnWhitespace() {
+        assertEquals(List.of("3", "spam", "again"), CommandArgs.tokenize("  3   spam\tagain "));
+    }
+
+    @Test
+    void quotesGroupWords() {
+        assertEquals(List.of("Bob Smith", "hi"), CommandArgs.tokenize("\"Bob Smith\" hi"));
+    }
+
+    @Test
+    void emptyQuotesAreAnArgument() {
+        assertEquals(List.of("", "x"), CommandArgs.tokenize("\"\" x"));
+    }
+
+    @Test
+    void apostrophesStayLiteral() {
+        assertEquals(List.of("O'Brien"), CommandArgs.tokenize("O'Brien"));
+    }
+
+    @Test
+    void unmatchedQuoteRunsToTheEnd() {
+        assertEquals(List.of("a", "b c "), CommandArgs.tokenize("a \"b c "));
+    }
+
+    @Test
+    void restJoinsTheRemainingTokens() {
+        List<String> tokens = CommandArgs.tokenize("2 too   rude");
+        assertEquals("too rude", CommandArgs.rest(tokens, 1));
+        assertEquals("", CommandArgs.rest(tokens, 3));
+    }
+
+    @Test
+    void quotedNameKeepsItsSpacing() {
+        assertEquals("Bob  Smith", CommandArgs.name("\"Bob  Smith\""));
+    }
+
+    @Test
+    void quotedNameIgnoresWhatFollows() {
+        assertEquals("Bob", CommandArgs.name("\"Bob\" Smith"));
+    }
+
+    @Test
+    void unquotedNameTakesEveryWord() {
+        assertEquals("Bob Smith", CommandArgs.name("Bob Smith"));
+        assertEquals("", CommandArgs.name(""));
+    }
+}
@@ -0,0 +1,44 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class DestroyRoomTest {
+
+    @Test
+    void creatorCanDestroy() throws Exception {
+        try (SessionHarness h = new SessionHarness(SessionHarness::asCreator)) {
+            h.type("/destroy");
+            h.awaitOutput("and all its messages for everyone? (y/N)");
+            h.type("y");
+            assertTrue(h.awaitExit(5_000), "session still running after destroying the room");
+            assertTrue(h.firebase.deleted);
+            assertFalse(h.firebase.left, "left a room it had just deleted");
+            assertTrue(h.output().contains("Room destroyed."));
+        }
+    }
+
+    @Test
+    void nonCreatorIsRefused() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("/destroy");
+            h.awaitOutput("Only the room creator can destroy the room.");
+            assertFalse(h.firebase.deleted);
+            assertTrue(h.session.isRunning());
+        }
+    }
+
+    @Test
+    void decliningKeepsTheRoom() throws Exception {
+        try (SessionHarness h = new SessionHarness(SessionHarness::asCreator)) {
+            h.type("/destroy");
+            h.awaitOutput("(y/N)");
+            h.type("n");
+            h.awaitOutput("Cancelled.");
+            assertFalse(h.firebase.deleted);
+            assertTrue(h.session.isRunning());
+        }
+    }
+}
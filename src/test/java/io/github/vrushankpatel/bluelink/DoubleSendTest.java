@@ -0,0 +1,47 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.firebase.Message;
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+
+class DoubleSendTest {
+
+    @Test
+    void doubleTappedEnterSendsOnce() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("hello");
+            h.type("hello");
+            h.awaitOutput("Not sent again");
+            assertEquals(List.of("hello"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void differentMessagesAreBothSent() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("hello");
+            h.type("/me hello");
+            h.await("both messages to be sent", () -> h.firebase.sent.size() == 2);
+        }
+    }
+
+    @Test
+    void messageEchoedTwiceIsShownOnce() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("hello");
+            h.await("the message to be sent", () -> h.firebase.sent.size() == 1);
+
+            // A retry that raced the original: the same client ID comes back under a second key
+            Message echo = new Message(h.config.getUsername(), h.config.getUserId(), h.config.getColor(),
+                    "hello", h.firebase.messages.get(0).getTimestamp());
+            echo.setClientId(h.firebase.messages.get(0).getClientId());
+            h.firebase.deliver(echo);
+            h.type("marker");
+            h.awaitOutput(": marker");
+            assertEquals(1, h.output().split(": hello", -1).length - 1);
+        }
+    }
+}
@@ -0,0 +1,217 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.firebase.FirebaseClient;
+import io.github.vrushankpatel.bluelink.firebase.Message;
+import io.github.vrushankpatel.bluelink.firebase.ReadOnlyException;
+import io.github.vrushankpatel.bluelink.firebase.RoomMeta;
+
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Set;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.CopyOnWriteArrayList;
+import java.util.concurrent.CountDownLatch;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.atomic.AtomicInteger;
+import java.util.function.Consumer;
+
+/**
+ * An in-memory room for driving a ChatSession in tests. Only the calls a session makes are
+ * faked; messages are stored as plaintext, and listeners run on the calling thread.
+ */
+class FakeFirebase extends FirebaseClient {
+
+    final List<Message> messages = new CopyOnWriteArrayList<>();   // the room's messages, oldest first
+    final List<String>  sent     = new CopyOnWriteArrayList<>();   // texts sendMessage accepted, in order
+    final AtomicInteger attempts = new AtomicInteger();            // sendMessage calls, accepted or not
+    final List<String>  actions  = new CopyOnWriteArrayList<>();   // reactions, reports and edits written, as "react <key>" etc.
+    final List<Long>    beats    = new CopyOnWriteArrayList<>();   // when updateActivity was called, in epoch millis
+    final Set<String>   rooms    = ConcurrentHashMap.newKeySet();  // other rooms that exist, for /join
+
+    volatile boolean failSends;             // sendMessage throws, as if the database were unreachable
+    volatile boolean refuseSends;           // sendMessage throws ReadOnlyException
+    volatile CountDownLatch sendGate;       // when set, sendMessage waits for it before storing
+    volatile boolean left;                  // leaveRoom was called
+    volatile boolean deleted;               // deleteRoom was called
+    volatile RoomMeta meta;
+
+    private final AtomicInteger keys = new AtomicInteger();
+    private volatile Consumer<Boolean>   connection;
+    private volatile Consumer<Message>   onMessage;
+    private volatile Consumer<Exception> onFailure;
+    private volatile Consumer<Message>   onEvent;
+
+    FakeFirebase() {
+        super(null);
+    }
+
+    /** True once the session has subscribed to the connection state, the last step of joining. */
+    boolean isWatched() { return connection != null; }
+
+    /** True while the session's live message listener is attached. */
+    boolean isListening() { return onMessage != null; }
+
+    /** True while the session's live event listener is attached. */
+    boolean isListeningForEvents() { return onEvent != null; }
+
+    /** Flips the connection as the SDK's .info/connected would. */
+    void setConnected(boolean up) {
+        connection.accept(up);
+    }
+
+    /** Cancels the live listener, as the database does when it drops the stream. */
+    void dropStream(Exception reason) {
+        Consumer<Message> live = onMessage;
+        onMessage = null;
+        if (live != null) onFailure.accept(reason);
+    }
+
+    /** Adds someone else's message to the room. */
+    Message receive(String sender, String text, long timestamp) {
+        Message msg = new Message(sender, "user_" + sender.toLowerCase(), "#00AAFF", text, timestamp);
+        store(msg);
+        return msg;
+    }
+
+    /** Announces a join/leave notice on the room's event stream. */
+    Message announce(String text) {
+        Message event = new Message("System", Message.SYSTEM_SENDER, null, text, System.currentTimeMillis() / 1000);
+        event.setKey(String.format("-N%08d", keys.incrementAndGet()));
+        Consumer<Message> live = onEvent;
+        if (live != null) live.accept(event);
+        return event;
+    }
+
+    /** Pushes msg under a new key, as a second write of the same message would. */
+    void deliver(Message msg) {
+        store(msg);
+    }
+
+    private void store(Message msg) {
+        msg.setKey(String.format("-N%08d", keys.incrementAndGet()));   // push keys sort in insertion order
+        messages.add(msg);
+        Consumer<Message> live = onMessage;
+        if (live != null) live.accept(msg);
+    }
+
+    // ── the calls a session makes ─────────────────────────────────────────────
+
+    @Override
+    public boolean joinRoom(String roomId, String userId, String username, String color,
+                            long debounceSeconds, long activeWindowSeconds) {
+        return false;
+    }
+
+    @Override
+    public void leaveRoom(String roomId, String userId, String parting) {
+        left = true;
+    }
+
+    @Override
+    public boolean checkRoomExists(String roomId) {
+        return rooms.contains(roomId);
+    }
+
+    @Override
+    public void deleteRoom(String roomId) {
+        deleted = true;
+    }
+
+    @Override
+    public RoomMeta getRoomMeta(String roomId) {
+        return meta;
+    }
+
+    @Override
+    public void sendMessage(String roomId, String userId, String username, String color, String text,
+                            String clientId, long ttlSeconds, String type) throws Exception {
+        attempts.incrementAndGet();
+        CountDownLatch gate = sendGate;
+        if (gate != null && !gate.await(10, TimeUnit.SECONDS)) throw new Exception("send gate never opened");
+        if (refuseSends) throw new ReadOnlyException("You are an observer in this room and can't post.");
+        if (failSends) throw new Exception("Firebase push timed out");
+        Message msg = new Message(username, userId, color, text, System.currentTimeMillis() / 1000);
+        msg.setClientId(clientId);
+        msg.setType(type);
+        sent.add(text);
+        store(msg);
+    }
+
+    @Override
+    public List<Message> pollMessages(String roomId, String afterKey, int limit) {
+        List<Message> result = new ArrayList<>();
+        for (Message msg : messages) {
+            if (afterKey == null || msg.getKey().compareTo(afterKey) >= 0) result.add(msg);
+        }
+        return result.subList(Math.max(0, result.size() - limit), result.size());
+    }
+
+    @Override
+    public List<Message> getInitialMessages(String roomId, int limit) {
+        return pollMessages(roomId, null, limit);
+    }
+
+    @Override
+    public Runnable listenForMessages(String roomId, String afterKey, Consumer<Message> onMessage,
+                                      Consumer<Message> onChanged, Consumer<String> onRemoved,
+                                      Consumer<Exception> onFailure) {
+        this.onFailure = onFailure;
+        this.onMessage = onMessage;
+        return () -> this.onMessage = null;
+    }
+
+    @Override
+    public List<Message> pollEvents(String roomId, String afterKey, int limit) {
+        return List.of();
+    }
+
+    @Override
+    public List<Message> getEvents(String roomId, int limit) {
+        return List.of();
+    }
+
+    @Override
+    public Runnable listenForEvents(String roomId, String afterKey, Consumer<Message> onEvent) {
+        this.onEvent = onEvent;
+        return () -> this.onEvent = null;
+    }
+
+    @Override
+    public Runnable watchConnection(Consumer<Boolean> listener) {
+        connection = listener;
+        return () -> {};
+    }
+
+    @Override
+    public Runnable watchRoom(String roomId, Runnable onGone) {
+        return () -> {};
+    }
+
+    @Override
+    public void addReaction(String roomId, String key, String userId, String emoji) {
+        actions.add("react " + key);
+    }
+
+    @Override
+    public void removeReaction(String roomId, String key, String userId, String emoji) {
+        actions.add("unreact " + key);
+    }
+
+    @Override
+    public void reportMessage(String roomId, String messageKey, String reporterId, String reason) {
+        actions.add("report " + messageKey);
+    }
+
+    @Override
+    public void editMessage(String roomId, String userId, String key, String newText) {
+        actions.add("edit " + key);
+    }
+
+    @Override
+    public void updateActivity(String roomId, String userId) {
+        beats.add(System.currentTimeMillis());
+    }
+
+    @Override
+    public void markSeen(String roomId, String userId, long timestamp) {}
+}
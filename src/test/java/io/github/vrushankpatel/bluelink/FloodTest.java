@@ -0,0 +1,47 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class FloodTest {
+
+    @Test
+    void floodSpreadOverSeveralBatchesFoldsIntoOneBlock() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            long now = System.currentTimeMillis() / 1000;
+            for (int n = 1; n <= 3; n++) h.firebase.receive("Mallory", "spam " + n, now);
+            h.awaitOutput("Mallory: spam 3");
+
+            // Three more make six within the window: those are folded, not printed
+            for (int n = 4; n <= 6; n++) h.firebase.receive("Mallory", "spam " + n, now);
+            h.awaitOutput("Mallory posted 3 more messages — /expand 1");
+            assertFalse(h.output().contains("Mallory: spam 4"));
+
+            // Later batches of the same flood join that block instead of starting another
+            h.firebase.receive("Mallory", "spam 7", now + 1);
+            h.awaitOutput("Mallory posted 1 more message — /expand 1");
+            assertFalse(h.output().contains("/expand 2"));
+
+            h.type("/expand 1");
+            h.awaitOutput("Mallory: spam 7");
+            assertTrue(h.output().contains("Mallory: spam 4"));
+        }
+    }
+
+    @Test
+    void anotherSenderEndsTheRun() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            long now = System.currentTimeMillis() / 1000;
+            for (int n = 1; n <= 3; n++) h.firebase.receive("Mallory", "spam " + n, now);
+            h.awaitOutput("Mallory: spam 3");
+            h.firebase.receive("Bob", "hi", now);
+            h.awaitOutput("Bob: hi");
+
+            for (int n = 4; n <= 6; n++) h.firebase.receive("Mallory", "spam " + n, now);
+            h.awaitOutput("Mallory: spam 6");
+            assertFalse(h.output().contains("/expand"));
+        }
+    }
+}
@@ -0,0 +1,25 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class HeartbeatTest {
+
+    private static final long INTERVAL_MS = 5_000;   // the shortest heartbeat UserConfig allows
+
+    @Test
+    void idleSessionKeepsRefreshingActivity() throws Exception {
+        try (SessionHarness h = new SessionHarness(s -> s.config.setHeartbeatSeconds(INTERVAL_MS / 1000))) {
+            long joined = System.currentTimeMillis();
+            h.await("two heartbeats", 3 * INTERVAL_MS, () -> h.firebase.beats.size() >= 2);
+
+            List<Long> beats = h.firebase.beats;
+            // Scheduled at a fixed rate, so neither beat comes early; allow some scheduling slack
+            assertTrue(beats.get(0) - joined >= INTERVAL_MS - 500, "first heartbeat came early");
+            assertTrue(beats.get(1) - beats.get(0) >= INTERVAL_MS - 500, "heartbeats came faster than configured");
+        }
+    }
+}
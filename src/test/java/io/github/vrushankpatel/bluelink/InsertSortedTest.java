@@ -0,0 +1,67 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.firebase.Message;
+import org.junit.jupiter.api.Test;
+
+import java.util.ArrayList;
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+
+class InsertSortedTest {
+
+    private static Message msg(String key, long timestamp) {
+        Message msg = new Message("Alice", "user_alice", "#FF0000", key, timestamp);
+        msg.setKey(key);
+        return msg;
+    }
+
+    private static List<String> keys(List<Message> list) {
+        return list.stream().map(Message::getKey).toList();
+    }
+
+    private static List<Message> backlog() {
+        List<Message> list = new ArrayList<>();
+        for (int i = 1; i <= 9; i += 2) list.add(msg(String.format("-N%03d", i), 1_000 + i));
+        return list;
+    }
+
+    @Test
+    void newestGoesOnTheEnd() {
+        List<Message> list = backlog();
+        ChatSession.insertSorted(list, msg("-N010", 1_010));
+        assertEquals(List.of("-N001", "-N003", "-N005", "-N007", "-N009", "-N010"), keys(list));
+    }
+
+    @Test
+    void olderMessageLandsInPlace() {
+        List<Message> list = backlog();
+        ChatSession.insertSorted(list, msg("-N004", 1_004));
+        assertEquals(List.of("-N001", "-N003", "-N004", "-N005", "-N007", "-N009"), keys(list));
+    }
+
+    @Test
+    void oldestGoesFirst() {
+        List<Message> list = backlog();
+        ChatSession.insertSorted(list, msg("-N000", 1_000));
+        assertEquals("-N000", list.get(0).getKey());
+        assertEquals(6, list.size());
+    }
+
+    @Test
+    void keyOrderWinsOverASkewedClock() {
+        // Sent after -N005 from a clock running an hour behind: still placed by its key
+        List<Message> list = backlog();
+        ChatSession.insertSorted(list, msg("-N006", 1_006 - 3_600));
+        assertEquals(List.of("-N001", "-N003", "-N005", "-N006", "-N007", "-N009"), keys(list));
+    }
+
+    @Test
+    void repeatOfAKeyGoesAfterIt() {
+        List<Message> list = backlog();
+        Message repeat = msg("-N005", 1_005);
+        ChatSession.insertSorted(list, repeat);
+        assertEquals(repeat, list.get(3));
+        assertEquals("-N005", list.get(2).getKey());
+    }
+}
@@ -0,0 +1,51 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class MentionsTest {
+
+    @Test
+    void trailingPunctuationIsNotPartOfTheName() {
+        assertEquals(List.of("bob"), Mentions.extract("thanks @bob,"));
+        assertEquals(List.of("bob"), Mentions.extract("ask @bob."));
+        assertEquals(List.of("bob"), Mentions.extract("@bob!"));
+        assertEquals(List.of("bob"), Mentions.extract("@bob-"));
+        assertEquals(List.of("bob"), Mentions.extract("@bob's turn"));
+    }
+
+    @Test
+    void surroundingBracketsAreFine() {
+        assertEquals(List.of("bob"), Mentions.extract("(@bob)"));
+        assertEquals(List.of("bob", "alice"), Mentions.extract("[@bob] and \"@alice\""));
+    }
+
+    @Test
+    void innerDotsAndDashesAreKept() {
+        assertEquals(List.of("bob.smith", "mary-jane"), Mentions.extract("@bob.smith @mary-jane"));
+    }
+
+    @Test
+    void addressesAndDoubledAtsAreNotMentions() {
+        assertEquals(List.of(), Mentions.extract("mail@example.com"));
+        assertEquals(List.of(), Mentions.extract("@@bob"));
+        assertEquals(List.of(), Mentions.extract("just an @ sign"));
+    }
+
+    @Test
+    void nonAsciiNamesWork() {
+        assertEquals(List.of("Zoë"), Mentions.extract("hi @Zoë!"));
+    }
+
+    @Test
+    void mentionsIgnoresCaseAndSpacesButNotLongerNames() {
+        assertTrue(Mentions.mentions("hey @bobsmith:", "Bob Smith"));
+        assertFalse(Mentions.mentions("hey @bobby", "Bob"));
+        assertFalse(Mentions.mentions(null, "Bob"));
+    }
+}
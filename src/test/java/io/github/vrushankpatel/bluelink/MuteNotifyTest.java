@@ -0,0 +1,38 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.config.UserConfig;
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class MuteNotifyTest {
+
+    @Test
+    void mutedSenderMentionDoesNotRing() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.config.setNotifyMuted(SessionHarness.ROOM, "user_bob", true);
+            String me = "@" + Mentions.handle(h.config.getUsername());
+
+            h.firebase.receive("Bob", me + " look at this", System.currentTimeMillis() / 1000);
+            h.awaitOutput("look at this");
+            assertFalse(h.output().contains("\007"), "a muted sender's mention rang the bell");
+
+            h.firebase.receive("Carol", me + " and this", System.currentTimeMillis() / 1000);
+            h.awaitOutput("and this");
+            assertTrue(h.output().contains("\007"), "an unmuted sender's mention didn't ring the bell");
+        }
+    }
+
+    @Test
+    void muteIsPerRoom() {
+        UserConfig config = UserConfig.guest();
+        config.setNotifyMuted("room-a", "user_bob", true);
+        assertTrue(config.isNotifyMuted("room-a", "user_bob"));
+        assertFalse(config.isNotifyMuted("room-b", "user_bob"));
+
+        config.setNotifyMuted("room-a", "user_bob", false);
+        assertFalse(config.isNotifyMuted("room-a", "user_bob"));
+        assertTrue(config.getNotifyMuted("room-a").isEmpty());
+    }
+}
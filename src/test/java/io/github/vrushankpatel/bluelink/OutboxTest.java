@@ -0,0 +1,94 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+import java.util.concurrent.CountDownLatch;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class OutboxTest {
+
+    @Test
+    void offlineSendIsQueuedWithoutTryingTheDatabase() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.setConnected(false);
+            h.type("hello");
+            h.awaitOutput("Queued (offline)");
+            assertEquals(0, h.firebase.attempts.get());
+
+            h.firebase.setConnected(true);
+            h.awaitOutput("Resent: hello");
+            assertEquals(List.of("hello"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void queuedMessagesAreSentInTheOrderTyped() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.setConnected(false);
+            h.type("one");
+            h.type("two");
+            h.type("three");
+            h.await("three messages to be queued", () -> h.output().split("Queued \\(offline\\)", -1).length == 4);
+
+            h.firebase.setConnected(true);
+            h.awaitOutput("Resent: three");
+            assertEquals(List.of("one", "two", "three"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void typingIsNotBlockedWhileTheOutboxFlushes() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.setConnected(false);
+            h.type("one");
+            h.awaitOutput("Queued (offline)");
+
+            // The reconnect's flush blocks in the network call...
+            CountDownLatch gate = new CountDownLatch(1);
+            h.firebase.sendGate = gate;
+            h.firebase.setConnected(true);
+            h.await("the flush to start sending", () -> h.firebase.attempts.get() == 1);
+
+            // ...while the input thread still queues what's typed
+            h.firebase.setConnected(false);
+            h.type("two");
+            h.await("the second message to be queued", () -> h.output().split("Queued \\(offline\\)", -1).length == 3);
+
+            gate.countDown();
+            h.awaitOutput("Resent: two");
+            assertEquals(List.of("one", "two"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void failedSendIsRetriedAutomatically() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.failSends = true;
+            h.type("hello");
+            h.awaitOutput("Retrying in 2s…");
+            assertTrue(!h.output().contains("/retry"), "a send that will be retried shouldn't point at /retry");
+
+            h.firebase.failSends = false;
+            h.awaitOutput("Resent: hello");
+            assertEquals(List.of("hello"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void refusedMessageIsDroppedNotResent() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.setConnected(false);
+            h.type("hello");
+            h.awaitOutput("Queued (offline)");
+
+            h.firebase.refuseSends = true;
+            h.firebase.setConnected(true);
+            h.awaitOutput("Not sent: hello");
+            assertTrue(h.firebase.sent.isEmpty());
+            assertTrue(!h.output().contains("Resent: hello"));
+        }
+    }
+}
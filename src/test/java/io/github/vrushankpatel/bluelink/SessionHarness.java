@@ -0,0 +1,108 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.config.UserConfig;
+import io.github.vrushankpatel.bluelink.firebase.RoomMeta;
+
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.io.PipedInputStream;
+import java.io.PipedOutputStream;
+import java.io.PrintStream;
+import java.nio.charset.StandardCharsets;
+import java.util.Scanner;
+import java.util.function.BooleanSupplier;
+import java.util.function.Consumer;
+
+import static org.junit.jupiter.api.Assertions.fail;
+
+/**
+ * Runs a ChatSession against a FakeFirebase on its own thread, typing lines into it and
+ * capturing what it prints. System.out is swapped while the harness is open, so tests
+ * using it mustn't run in parallel.
+ */
+final class SessionHarness implements AutoCloseable {
+
+    static final String ROOM = "12345678";
+    private static final long TIMEOUT_MS = 5_000;
+
+    final FakeFirebase firebase = new FakeFirebase();
+    final UserConfig   config   = UserConfig.guest();
+    final ChatSession  session;
+
+    private final PipedOutputStream     input  = new PipedOutputStream();
+    private final ByteArrayOutputStream output = new ByteArrayOutputStream();
+    private final PrintStream           stdout = System.out;
+    private final Thread                thread;
+
+    SessionHarness(String... args) throws IOException {
+        this(h -> {}, args);
+    }
+
+    /** Runs setup on the config and fake room before the session joins. */
+    SessionHarness(Consumer<SessionHarness> setup, String... args) throws IOException {
+        setup.accept(this);
+        Scanner scanner = new Scanner(new PipedInputStream(input, 64 * 1024), StandardCharsets.UTF_8);
+        System.setOut(new PrintStream(output, true, StandardCharsets.UTF_8));
+        session = new ChatSession(ROOM, config, firebase, scanner, CliOptions.parse(args));
+        thread  = new Thread(session::run, "session-under-test");
+        thread.setDaemon(true);
+        thread.start();
+        await("the session to join", firebase::isWatched);
+    }
+
+    /** A setup that makes this session's user the room's creator. */
+    static void asCreator(SessionHarness h) {
+        h.firebase.meta = new RoomMeta(h.config.getUserId(), System.currentTimeMillis() / 1000, 0);
+    }
+
+    /** Types a line, as if followed by Enter. */
+    void type(String line) throws IOException {
+        input.write((line + "\n").getBytes(StandardCharsets.UTF_8));
+        input.flush();
+    }
+
+    /** Ends input, as Ctrl+D does. */
+    void endInput() throws IOException {
+        input.close();
+    }
+
+    /** Everything printed so far. */
+    String output() {
+        return output.toString(StandardCharsets.UTF_8);
+    }
+
+    /** Waits for the output to contain text. */
+    void awaitOutput(String text) {
+        await("\"" + text + "\" to be printed", () -> output().contains(text));
+    }
+
+    void await(String what, BooleanSupplier condition) {
+        await(what, TIMEOUT_MS, condition);
+    }
+
+    void await(String what, long timeoutMillis, BooleanSupplier condition) {
+        long deadline = System.currentTimeMillis() + timeoutMillis;
+        while (!condition.getAsBoolean()) {
+            if (System.currentTimeMillis() > deadline) fail("Timed out waiting for " + what + ". Output:\n" + output());
+            try {
+                Thread.sleep(20);
+            } catch (InterruptedException e) {
+                Thread.currentThread().interrupt();
+                fail("Interrupted waiting for " + what);
+            }
+        }
+    }
+
+    /** True if the session's thread has returned within millis. */
+    boolean awaitExit(long millis) throws InterruptedException {
+        thread.join(millis);
+        return !thread.isAlive();
+    }
+
+    @Override
+    public void close() throws IOException {
+        session.stop();
+        input.close();
+        System.setOut(stdout);
+    }
+}
@@ -0,0 +1,46 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class ShutdownTest {
+
+    @Test
+    void endOfInputLeavesTheRoom() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            assertTrue(h.firebase.isListening());
+            h.endInput();
+            assertTrue(h.awaitExit(5_000), "session still running after input closed");
+            assertFalse(h.session.isRunning());
+            assertTrue(h.firebase.left);
+            assertFalse(h.firebase.isListening());
+        }
+    }
+
+    @Test
+    void stoppedSessionIgnoresFurtherInput() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.session.stop();
+            assertFalse(h.session.isRunning());
+            assertTrue(h.firebase.left);
+            assertFalse(h.firebase.isListening());
+
+            // The input loop notices on the next line and returns without sending it
+            h.type("hello");
+            assertTrue(h.awaitExit(5_000), "session still running after stop()");
+            assertTrue(h.firebase.sent.isEmpty());
+        }
+    }
+
+    @Test
+    void stopIsIdempotent() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.session.stop();
+            h.firebase.left = false;
+            h.session.stop();
+            assertFalse(h.firebase.left, "second stop() left the room again");
+        }
+    }
+}
@@ -0,0 +1,40 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class SlashEscapeTest {
+
+    @Test
+    void doubleSlashSendsALeadingSlash() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("//etc/passwd");
+            h.await("the message to be sent", () -> h.firebase.sent.size() == 1);
+            assertEquals(List.of("/etc/passwd"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void sayPostsACommandAsText() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("/say /help is how you list commands");
+            h.await("the message to be sent", () -> h.firebase.sent.size() == 1);
+            assertEquals(List.of("/help is how you list commands"), h.firebase.sent);
+        }
+    }
+
+    @Test
+    void unknownCommandIsNotSent() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("/etc/passwd");
+            h.awaitOutput("Unknown command: /etc/passwd");
+            h.type("/say");
+            h.awaitOutput("Usage: /say <text>");
+            assertTrue(h.firebase.sent.isEmpty());
+        }
+    }
+}
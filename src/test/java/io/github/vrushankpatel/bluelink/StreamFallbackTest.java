@@ -0,0 +1,40 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+
+class StreamFallbackTest {
+
+    @Test
+    void droppedStreamFallsBackToPolling() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            long now = System.currentTimeMillis() / 1000;
+            h.firebase.receive("Bob", "before the drop", now);
+            h.awaitOutput("Bob: before the drop");
+
+            h.firebase.dropStream(new Exception("permission denied"));
+            h.awaitOutput("Live updates unavailable (permission denied) — polling instead.");
+            assertFalse(h.firebase.isListening());
+
+            // Nothing is pushed any more, so this only shows up if the poll picks it up
+            h.firebase.receive("Bob", "after the drop", now + 1);
+            h.awaitOutput("Bob: after the drop");
+        }
+    }
+
+    @Test
+    void pollingDoesNotRepeatStreamedMessages() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            long now = System.currentTimeMillis() / 1000;
+            h.firebase.receive("Bob", "streamed", now);
+            h.awaitOutput("Bob: streamed");
+
+            h.firebase.dropStream(new Exception("stream closed"));
+            h.firebase.receive("Bob", "polled", now + 1);
+            h.awaitOutput("Bob: polled");
+            assertEquals(1, h.output().split("Bob: streamed", -1).length - 1);
+        }
+    }
+}
@@ -0,0 +1,48 @@
+package io.github.vrushankpatel.bluelink;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.HashSet;
+import java.util.Set;
+
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class SwitchRoomTest {
+
+    @Test
+    void joinLeavesNothingRunning() throws Exception {
+        Set<Thread> before = new HashSet<>(Thread.getAllStackTraces().keySet());
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.rooms.add("87654321");
+            assertTrue(h.firebase.isListening());
+            assertTrue(h.firebase.isListeningForEvents());
+
+            h.type("/join 87654321");
+            assertTrue(h.awaitExit(5_000), "session still running after /join");
+            assertTrue(h.firebase.left);
+            assertFalse(h.firebase.isListening(), "message listener still attached");
+            assertFalse(h.firebase.isListeningForEvents(), "event listener still attached");
+
+            // The scheduler, heartbeat and other executors are all shut down, not just idle
+            h.await("the session's threads to end", () -> leftover(before).isEmpty());
+        }
+    }
+
+    @Test
+    void missingRoomKeepsTheSession() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("/join 87654321");
+            h.awaitOutput("Room 87654321 does not exist.");
+            assertTrue(h.session.isRunning());
+            assertTrue(h.firebase.isListening());
+        }
+    }
+
+    private static Set<Thread> leftover(Set<Thread> before) {
+        Set<Thread> now = new HashSet<>(Thread.getAllStackTraces().keySet());
+        now.removeAll(before);
+        now.removeIf(t -> !t.isAlive());
+        return now;
+    }
+}
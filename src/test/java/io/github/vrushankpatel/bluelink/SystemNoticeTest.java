@@ -0,0 +1,60 @@
+package io.github.vrushankpatel.bluelink;
+
+import io.github.vrushankpatel.bluelink.firebase.Message;
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class SystemNoticeTest {
+
+    @Test
+    void reactingToANoticeIsRefused() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.announce("Bob joined the room");
+            h.awaitOutput("Bob joined the room");
+            h.type("/react 1 👍");
+            h.awaitOutput("That's a system notice — you can only react to messages people sent.");
+            assertTrue(h.firebase.actions.isEmpty());
+        }
+    }
+
+    @Test
+    void reportingANoticeIsRefused() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.announce("Bob left the room");
+            h.awaitOutput("Bob left the room");
+            h.type("/report 1 spam");
+            h.awaitOutput("That's a system notice — you can only report messages people sent.");
+            assertTrue(h.firebase.actions.isEmpty());
+        }
+    }
+
+    @Test
+    void editSkipsNoticesForYourOwnMessage() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.type("first draft");
+            h.await("the message to be sent", () -> h.firebase.sent.size() == 1);
+            Message mine = h.firebase.messages.get(0);
+            h.firebase.announce("Bob joined the room");
+            h.awaitOutput("Bob joined the room");
+
+            h.type("/edit 1 second draft");
+            h.await("the edit to be written", () -> !h.firebase.actions.isEmpty());
+            assertEquals(List.of("edit " + mine.getKey()), h.firebase.actions);
+        }
+    }
+
+    @Test
+    void editWithOnlyANoticeIsRefused() throws Exception {
+        try (SessionHarness h = new SessionHarness()) {
+            h.firebase.announce("Bob joined the room");
+            h.awaitOutput("Bob joined the room");
+            h.type("/edit 1 hijacked");
+            h.awaitOutput("You have no message at index 1.");
+            assertTrue(h.firebase.actions.isEmpty());
+        }
+    }
+}
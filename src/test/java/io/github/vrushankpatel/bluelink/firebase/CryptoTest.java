@@ -0,0 +1,116 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import org.junit.jupiter.api.Test;
+
+import javax.crypto.AEADBadTagException;
+import javax.crypto.Cipher;
+import javax.crypto.spec.GCMParameterSpec;
+import javax.crypto.spec.SecretKeySpec;
+import java.nio.charset.StandardCharsets;
+import java.util.Arrays;
+import java.util.Base64;
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertNotEquals;
+import static org.junit.jupiter.api.Assertions.assertThrows;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class CryptoTest {
+
+    private static final String ROOM   = "12345678";
+    private static final String SENDER = "user_abc";
+
+    private static byte[] key() throws Exception {
+        return Crypto.deriveKey(ROOM, null);
+    }
+
+    @Test
+    void roundTrips() throws Exception {
+        String sealed = Crypto.encrypt("hello ✓", key(), ROOM, SENDER);
+        assertEquals("hello ✓", Crypto.decrypt(sealed, key(), ROOM, SENDER));
+    }
+
+    @Test
+    void sameTextSealsDifferentlyEachTime() throws Exception {
+        String first  = Crypto.encrypt("hello", key(), ROOM, SENDER);
+        String second = Crypto.encrypt("hello", key(), ROOM, SENDER);
+        assertNotEquals(first, second);
+        // A fresh nonce each time, not just a different tail
+        byte[] a = Base64.getDecoder().decode(first);
+        byte[] b = Base64.getDecoder().decode(second);
+        assertFalse(Arrays.equals(Arrays.copyOf(a, 12), Arrays.copyOf(b, 12)), "nonce was reused");
+        assertEquals("hello", Crypto.decrypt(second, key(), ROOM, SENDER));
+    }
+
+    @Test
+    void replayIntoAnotherRoomFails() throws Exception {
+        String sealed = Crypto.encrypt("hello", key(), ROOM, SENDER);
+        assertThrows(AEADBadTagException.class, () -> Crypto.decrypt(sealed, key(), "87654321", SENDER));
+    }
+
+    @Test
+    void claimingAnotherSenderFails() throws Exception {
+        String sealed = Crypto.encrypt("hello", key(), ROOM, SENDER);
+        assertThrows(AEADBadTagException.class, () -> Crypto.decrypt(sealed, key(), ROOM, "user_mallory"));
+    }
+
+    @Test
+    void tamperedCiphertextFails() throws Exception {
+        byte[] raw = Base64.getDecoder().decode(Crypto.encrypt("hello", key(), ROOM, SENDER));
+        raw[raw.length - 1] ^= 1;
+        String tampered = Base64.getEncoder().encodeToString(raw);
+        assertThrows(AEADBadTagException.class, () -> Crypto.decrypt(tampered, key(), ROOM, SENDER));
+    }
+
+    @Test
+    void wrongKeyFails() throws Exception {
+        String sealed = Crypto.encrypt("hello", key(), ROOM, SENDER);
+        assertThrows(AEADBadTagException.class, () -> Crypto.decrypt(sealed, Crypto.deriveKey("87654321", null), ROOM, SENDER));
+    }
+
+    /** A body as clients sealed it before AAD binding: [nonce | ciphertext+tag], no associated data. */
+    private static String sealLegacy(String text, byte[] key) throws Exception {
+        byte[] nonce = new byte[12];
+        Cipher cipher = Cipher.getInstance("AES/GCM/NoPadding");
+        cipher.init(Cipher.ENCRYPT_MODE, new SecretKeySpec(key, "AES"), new GCMParameterSpec(128, nonce));
+        byte[] sealed = cipher.doFinal(text.getBytes(StandardCharsets.UTF_8));
+        byte[] raw = new byte[nonce.length + sealed.length];
+        System.arraycopy(sealed, 0, raw, nonce.length, sealed.length);
+        return Base64.getEncoder().encodeToString(raw);
+    }
+
+    @Test
+    void legacyBodyNeedsTheLegacyPath() throws Exception {
+        String legacy = sealLegacy("hello", key());
+        assertThrows(AEADBadTagException.class, () -> Crypto.decrypt(legacy, key(), ROOM, SENDER));
+        assertEquals("hello", Crypto.decryptLegacy(legacy, key()));
+    }
+
+    @Test
+    void legacyMessageCreditedToAnotherSenderIsUnverified() throws Exception {
+        Message copied = new Message("Mallory", "user_mallory", "#FF0000", sealLegacy("hello", key()), 1_700_000_000L);
+        FirebaseClient.decryptWith(copied, ROOM, List.of(key()));
+        assertEquals("hello", copied.getText());
+        assertTrue(copied.isUnverified());
+    }
+
+    @Test
+    void boundMessageIsNeverOpenedAsLegacy() throws Exception {
+        // Someone re-wraps an old body in a message claiming to be bound: it must not open at all
+        Message forged = new Message("Mallory", "user_mallory", "#FF0000", sealLegacy("hello", key()), 1_700_000_000L);
+        forged.setBound(true);
+        FirebaseClient.decryptWith(forged, ROOM, List.of(key()));
+        assertTrue(forged.isUndecryptable());
+    }
+
+    @Test
+    void boundMessageIsVerified() throws Exception {
+        Message msg = new Message("Alice", SENDER, "#FF0000", Crypto.encrypt("hello", key(), ROOM, SENDER), 1_700_000_000L);
+        msg.setBound(true);
+        FirebaseClient.decryptWith(msg, ROOM, List.of(key()));
+        assertEquals("hello", msg.getText());
+        assertFalse(msg.isUnverified());
+    }
+}
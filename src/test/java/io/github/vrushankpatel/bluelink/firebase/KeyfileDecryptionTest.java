@@ -0,0 +1,62 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertArrayEquals;
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class KeyfileDecryptionTest {
+
+    private static final String ROOM   = "12345678";
+    private static final String SENDER = "user_abc";
+    private static final byte[] SECRET = new byte[32];
+
+    static {
+        for (int i = 0; i < SECRET.length; i++) SECRET[i] = (byte) (i * 7 + 1);
+    }
+
+    private static Message sealed(String text, byte[] key) throws Exception {
+        return new Message("Alice", SENDER, "#FF0000", Crypto.encrypt(text, key, ROOM, SENDER), 1_700_000_000L);
+    }
+
+    @Test
+    void roomIdKeyRoundTrips() throws Exception {
+        byte[] key = Crypto.deriveKey(ROOM, null);
+        Message msg = FirebaseClient.decryptWith(sealed("hello", key), ROOM, List.of(key));
+        assertEquals("hello", msg.getText());
+        assertFalse(msg.isUndecryptable());
+    }
+
+    @Test
+    void keyfileKeyRoundTrips() throws Exception {
+        byte[] key = Crypto.deriveKey(SECRET, null);
+        Message msg = FirebaseClient.decryptWith(sealed("hello", key), ROOM, List.of(key));
+        assertEquals("hello", msg.getText());
+    }
+
+    @Test
+    void keyfileKeyIsTheSecretNotTheRoomId() throws Exception {
+        assertArrayEquals(SECRET, Crypto.deriveKey(SECRET, null));
+        assertFalse(java.util.Arrays.equals(Crypto.deriveKey(ROOM, null), Crypto.deriveKey(SECRET, null)));
+    }
+
+    @Test
+    void keyfileHolderStillReadsRoomIdMessages() throws Exception {
+        byte[] keyfile = Crypto.deriveKey(SECRET, null);
+        byte[] roomId  = Crypto.deriveKey(ROOM, null);
+        Message msg = FirebaseClient.decryptWith(sealed("from before the keyfile", roomId), ROOM, List.of(keyfile, roomId));
+        assertEquals("from before the keyfile", msg.getText());
+    }
+
+    @Test
+    void roomIdKeyCannotReadKeyfileMessages() throws Exception {
+        byte[] keyfile = Crypto.deriveKey(SECRET, null);
+        Message msg = FirebaseClient.decryptWith(sealed("secret", keyfile), ROOM, List.of(Crypto.deriveKey(ROOM, null)));
+        assertTrue(msg.isUndecryptable());
+        assertEquals(FirebaseClient.UNDECRYPTABLE, msg.getText());
+    }
+}
@@ -0,0 +1,28 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import org.junit.jupiter.api.Test;
+
+import static org.junit.jupiter.api.Assertions.assertDoesNotThrow;
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertThrows;
+
+class OwnershipTest {
+
+    @Test
+    void ownMessageMayBeDeleted() {
+        assertDoesNotThrow(() -> FirebaseClient.requireOwner("user_alice", "user_alice", "delete"));
+    }
+
+    @Test
+    void someoneElsesMessageMayNotBeDeleted() {
+        IllegalArgumentException e = assertThrows(IllegalArgumentException.class,
+                () -> FirebaseClient.requireOwner("user_alice", "user_bob", "delete"));
+        assertEquals("You can only delete your own messages.", e.getMessage());
+    }
+
+    @Test
+    void vanishedMessageMayNotBeEdited() {
+        // getValue returns null once the message is gone
+        assertThrows(IllegalArgumentException.class, () -> FirebaseClient.requireOwner("user_alice", null, "edit"));
+    }
+}
@@ -0,0 +1,77 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import org.junit.jupiter.api.BeforeAll;
+import org.junit.jupiter.api.Test;
+
+import java.util.Base64;
+import java.util.List;
+
+import static org.junit.jupiter.api.Assertions.assertArrayEquals;
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertNotNull;
+import static org.junit.jupiter.api.Assertions.assertNull;
+import static org.junit.jupiter.api.Assertions.assertThrows;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class PassphraseTest {
+
+    private static final String ROOM   = "12345678";
+    private static final String SENDER = "user_abc";
+    private static final String RIGHT  = "correct horse battery staple";
+
+    private static byte[]   salt;
+    private static byte[]   key;
+    private static RoomMeta meta;
+
+    // PBKDF2 is slow on purpose, so the room is set up once
+    @BeforeAll
+    static void createRoom() throws Exception {
+        salt = Crypto.newSalt();
+        key  = Crypto.stretch(RIGHT, salt);
+        meta = new RoomMeta(SENDER, 1_700_000_000L, 0);
+        meta.setPassphraseProtected(true);
+        meta.setKeyDerivation(Base64.getEncoder().encodeToString(salt), Crypto.keyCheck(key));
+    }
+
+    @Test
+    void rightPassphraseGivesTheRoomKey() throws Exception {
+        assertArrayEquals(key, FirebaseClient.checkPassphrase(meta, RIGHT));
+    }
+
+    @Test
+    void wrongPassphraseIsRejected() throws Exception {
+        assertNull(FirebaseClient.checkPassphrase(meta, "correct horse battery stapler"));
+    }
+
+    @Test
+    void wrongPassphraseCannotReadMessages() throws Exception {
+        Message msg = new Message("Alice", SENDER, "#FF0000", Crypto.encrypt("hello", key, ROOM, SENDER), 1_700_000_000L);
+        byte[] wrong = Crypto.stretch("Tr0ub4dor&3", salt);
+        FirebaseClient.decryptWith(msg, ROOM, List.of(wrong));
+        assertTrue(msg.isUndecryptable());
+        assertEquals(FirebaseClient.UNDECRYPTABLE, msg.getText());
+    }
+
+    @Test
+    void saltMakesTheSamePassphraseGiveAnotherKey() throws Exception {
+        assertFalse(java.util.Arrays.equals(key, Crypto.stretch(RIGHT, Crypto.newSalt())));
+    }
+
+    @Test
+    void crossPostUsesTheMembersKey() throws Exception {
+        assertArrayEquals(key, FirebaseClient.crossPostKey(ROOM, meta, null, RIGHT));
+    }
+
+    @Test
+    void crossPostWithTheWrongPassphraseIsRefused() {
+        assertThrows(IllegalArgumentException.class, () -> FirebaseClient.crossPostKey(ROOM, meta, null, "Tr0ub4dor&3"));
+    }
+
+    @Test
+    void roomsWithoutACheckTakeAnyPassphrase() throws Exception {
+        RoomMeta unchecked = new RoomMeta(SENDER, 1_700_000_000L, 0);
+        unchecked.setKeyDerivation(Base64.getEncoder().encodeToString(salt), null);
+        assertNotNull(FirebaseClient.checkPassphrase(unchecked, "anything"));
+    }
+}
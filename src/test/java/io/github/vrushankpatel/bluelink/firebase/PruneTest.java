@@ -0,0 +1,58 @@
+package io.github.vrushankpatel.bluelink.firebase;
+
+import org.junit.jupiter.api.Test;
+
+import java.util.LinkedHashMap;
+import java.util.Map;
+import java.util.Set;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertTrue;
+
+class PruneTest {
+
+    private static final long CUTOFF = 1_000;
+
+    /** A room's messages by push key; keys sort in sending order. */
+    private static Map<String, Long> room(long... timestamps) {
+        Map<String, Long> room = new LinkedHashMap<>();
+        for (int i = 0; i < timestamps.length; i++) room.put(String.format("-N%03d", i), timestamps[i]);
+        return room;
+    }
+
+    @Test
+    void deletesOnlyMessagesBeforeTheCutoff() {
+        Set<String> prunable = FirebaseClient.selectPrunable(room(100, 200, 300, 1_000, 1_500), CUTOFF, 0);
+        assertEquals(Set.of("-N000", "-N001", "-N002"), prunable);
+    }
+
+    @Test
+    void newerMessagesSurvive() {
+        Set<String> prunable = FirebaseClient.selectPrunable(room(100, 1_200, 1_300), CUTOFF, 0);
+        assertEquals(Set.of("-N000"), prunable);
+    }
+
+    @Test
+    void newestKeepSurviveWhateverTheirAge() {
+        Set<String> prunable = FirebaseClient.selectPrunable(room(100, 200, 300, 400), CUTOFF, 2);
+        assertEquals(Set.of("-N000", "-N001"), prunable);
+    }
+
+    @Test
+    void keepCountsNewMessagesToo() {
+        // Two recent messages already fill keep, so every old one goes
+        Set<String> prunable = FirebaseClient.selectPrunable(room(100, 200, 1_100, 1_200), CUTOFF, 2);
+        assertEquals(Set.of("-N000", "-N001"), prunable);
+    }
+
+    @Test
+    void equalTimestampsKeepTheLaterKey() {
+        Set<String> prunable = FirebaseClient.selectPrunable(room(500, 500, 500), CUTOFF, 1);
+        assertEquals(Set.of("-N000", "-N001"), prunable);
+    }
+
+    @Test
+    void quietRoomIsLeftAlone() {
+        assertTrue(FirebaseClient.selectPrunable(room(100, 200), CUTOFF, 5).isEmpty());
+    }
+}